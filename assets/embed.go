@@ -0,0 +1,11 @@
+// Package assets ships the default image descriptions within the binary.
+package assets
+
+import _ "embed"
+
+//go:generate go run gen.go
+
+// Catalog is the JSON-lines catalog generated from the JSON files in the images directory.
+//
+//go:embed catalog.jsonl
+var Catalog []byte
@@ -0,0 +1,49 @@
+//go:build ignore
+
+// gen.go generates catalog.jsonl from the JSON files in the images directory,
+// since go:embed does not accept the colons in the image file names. The
+// files are copied as is, with all their fields, to be parsed by the tool like
+// the files of an asset directory, and named after their path from the
+// working directory of the tool, as they are when loaded from assets/images.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func main() {
+	files, err := filepath.Glob(filepath.Join("images", "*.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(files)
+
+	out, err := os.Create("catalog.jsonl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+	encoder := json.NewEncoder(out)
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(b, &fields); err != nil {
+			log.Fatalf("failed to parse %s: %v", file, err)
+		}
+		name, err := json.Marshal(filepath.Join("assets", file))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fields["name"] = name
+		if err := encoder.Encode(fields); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package asset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"oras.land/oras-go/v2/registry"
+
+	"github.com/billy-playground/registry-load-tester/assets"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/image"
+)
+
+// Embedded is the source name of the asset set shipped within the binary.
+const Embedded = "embedded"
+
+// Asset is a named image description.
+type Asset struct {
+	// Name identifies the asset in the results, e.g. the path of its JSON file.
+	Name string `json:"name,omitempty"`
+	image.Data
+	// Fields holds all the raw fields of the asset JSON, including the custom ones.
+	Fields map[string]json.RawMessage `json:"-"`
+}
+
+// Number returns the value of a numeric field of the asset JSON.
+func (a *Asset) Number(field string) (float64, bool) {
+	raw, ok := a.Fields[field]
+	if !ok {
+		return 0, false
+	}
+	var value float64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// unmarshal parses the asset JSON, keeping all the raw fields.
+func (a *Asset) unmarshal(b []byte) error {
+	if err := json.Unmarshal(b, a); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &a.Fields)
+}
+
+// Validate checks that the manifest and all the blobs are valid references.
+func (a *Asset) Validate() error {
+	if a.Manifest == "" {
+		return errors.New("manifest is empty")
+	}
+	if _, err := registry.ParseReference(a.Manifest); err != nil {
+		return fmt.Errorf("invalid manifest reference: %w", err)
+	}
+	if a.Size < 0 {
+		return fmt.Errorf("invalid size %d", a.Size)
+	}
+	for _, blob := range a.Blobs {
+		ref, err := registry.ParseReference(blob)
+		if err != nil {
+			return fmt.Errorf("invalid blob reference: %w", err)
+		}
+		if _, err := ref.Digest(); err != nil {
+			return fmt.Errorf("blob %q is not referenced by digest: %w", blob, err)
+		}
+	}
+	return nil
+}
+
+// Source loads assets.
+type Source interface {
+	// Load loads and validates all the assets of the source.
+	Load() ([]Asset, error)
+}
+
+// NewSource returns the source described by input, which can be one of the following:
+//
+//	embedded: the asset set shipped within the binary
+//	<directory>: a directory of JSON files, one image description per file
+//	<file>: a JSON-lines catalog file, one image description per line
+func NewSource(input string) (Source, error) {
+	if input == Embedded {
+		return &catalogSource{name: Embedded, open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(assets.Catalog)), nil
+		}}, nil
+	}
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dirSource{dir: input}, nil
+	}
+	return &catalogSource{name: input, open: func() (io.ReadCloser, error) {
+		return os.Open(input)
+	}}, nil
+}
+
+// dirSource loads assets from the JSON files in a directory.
+type dirSource struct {
+	dir string
+}
+
+// Load loads the JSON files in the directory, sorted by name.
+func (s *dirSource) Load() ([]Asset, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	assets := make([]Asset, 0, len(files))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		a := Asset{Name: file}
+		if err := a.unmarshal(b); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		if err := a.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid asset %s: %w", file, err)
+		}
+		assets = append(assets, a)
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("no JSON files found in %s", s.dir)
+	}
+	return assets, nil
+}
+
+// catalogSource loads assets from a JSON-lines catalog.
+type catalogSource struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// Load loads the catalog lines. Assets without a name are named after their
+// line number in the catalog.
+func (s *catalogSource) Load() ([]Asset, error) {
+	rc, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var assets []Asset
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		b := bytes.TrimSpace(scanner.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var a Asset
+		if err := a.unmarshal(b); err != nil {
+			return nil, fmt.Errorf("failed to parse %s line %d: %w", s.name, line, err)
+		}
+		if a.Name == "" {
+			a.Name = fmt.Sprintf("%s:%d", s.name, line)
+		}
+		if err := a.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid asset %s: %w", a.Name, err)
+		}
+		assets = append(assets, a)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.name, err)
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("no assets found in %s", s.name)
+	}
+	return assets, nil
+}
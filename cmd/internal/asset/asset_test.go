@@ -0,0 +1,131 @@
+package asset
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const (
+	validManifest = "registry.example.com/app@sha256:e1ab76e6964f6e28910e28b667150f79ce4e35a7c5b60ef0ab48bb2f8f077b36"
+	validBlob     = "registry.example.com/app@sha256:0bc2f60ebab11e2311bbbccf17169edbb0434511c9ebeeaa2691b16e47be9e1c"
+)
+
+func TestNewSource(t *testing.T) {
+	dir := t.TempDir()
+	imagesDir := filepath.Join(dir, "images")
+	if err := os.Mkdir(imagesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(imagesDir, "b.json"), `{"size": 1, "manifest": "`+validManifest+`", "blob": ["`+validBlob+`"]}`)
+	writeFile(t, filepath.Join(imagesDir, "a.json"), `{"size": 2, "manifest": "`+validManifest+`", "blob": []}`)
+	writeFile(t, filepath.Join(dir, "catalog.jsonl"), `{"name": "first", "size": 1, "manifest": "`+validManifest+`", "blob": ["`+validBlob+`"]}
+
+{"size": 2, "manifest": "`+validManifest+`"}
+`)
+	writeFile(t, filepath.Join(dir, "invalid.jsonl"), `{"size": 1, "manifest": "`+validManifest+`", "blob": ["registry.example.com/app:latest"]}`)
+
+	tests := []struct {
+		name      string
+		input     string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:      "Directory of JSON files",
+			input:     imagesDir,
+			wantNames: []string{filepath.Join(imagesDir, "a.json"), filepath.Join(imagesDir, "b.json")},
+		},
+		{
+			name:      "JSON-lines catalog",
+			input:     filepath.Join(dir, "catalog.jsonl"),
+			wantNames: []string{"first", filepath.Join(dir, "catalog.jsonl") + ":3"},
+		},
+		{
+			name:    "Blob not referenced by digest",
+			input:   filepath.Join(dir, "invalid.jsonl"),
+			wantErr: true,
+		},
+		{
+			name:    "Empty directory",
+			input:   t.TempDir(),
+			wantErr: true,
+		},
+		{
+			name:    "Missing source",
+			input:   filepath.Join(dir, "missing"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assets, err := load(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if len(assets) != len(tt.wantNames) {
+				t.Fatalf("Load() returned %d assets, want %d", len(assets), len(tt.wantNames))
+			}
+			for i, a := range assets {
+				if a.Name != tt.wantNames[i] {
+					t.Errorf("Load() asset %d name = %q, want %q", i, a.Name, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddedSource(t *testing.T) {
+	assets, err := load(Embedded)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(assets) == 0 {
+		t.Fatal("Load() returned no embedded assets")
+	}
+
+	// the embedded assets are loaded like the files they are generated from
+	files, err := load(filepath.Join("..", "..", "..", "assets", "images"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(assets) != len(files) {
+		t.Fatalf("Load() returned %d embedded assets, want %d", len(assets), len(files))
+	}
+	for i, a := range assets {
+		want := files[i]
+		if name := filepath.Join("assets", "images", filepath.Base(want.Name)); a.Name != name {
+			t.Errorf("embedded asset %d name = %q, want %q", i, a.Name, name)
+		}
+		delete(a.Fields, "name")
+		// the raw fields are compacted in the catalog
+		got, _ := json.Marshal(a.Fields)
+		wantFields, _ := json.Marshal(want.Fields)
+		if !reflect.DeepEqual(a.Data, want.Data) || string(got) != string(wantFields) {
+			t.Errorf("embedded asset %s differs from %s", a.Name, want.Name)
+		}
+	}
+}
+
+func load(input string) ([]Asset, error) {
+	source, err := NewSource(input)
+	if err != nil {
+		return nil, err
+	}
+	return source.Load()
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/internal/trace"
+)
+
+// Exporter exposes the metrics of the records of a run in the Prometheus
+// format. It implements result.Writer so that it can be fed along with the
+// results output.
+type Exporter struct {
+	registryDomain string
+	registry       *prometheus.Registry
+	operations     *prometheus.CounterVec
+	durations      *prometheus.HistogramVec
+	bytes          *prometheus.CounterVec
+	retries        *prometheus.CounterVec
+	errors         *prometheus.CounterVec
+	active         prometheus.Gauge
+}
+
+// New returns an exporter of the metrics of a run against the registry.
+func New(registryDomain string) *Exporter {
+	e := &Exporter{
+		registryDomain: registryDomain,
+		registry:       prometheus.NewRegistry(),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rlt_operations_total",
+			Help: "Number of completed auth exchanges, manifest and blob fetches.",
+		}, []string{"operation", "status_class", "registry"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "rlt_operation_duration_seconds",
+			Help:    "Duration of the auth exchanges, manifest and blob fetches, including their retries.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"operation", "status_class", "registry"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rlt_downloaded_bytes_total",
+			Help: "Number of bytes downloaded by the manifest and blob fetches.",
+		}, []string{"operation", "registry"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rlt_retries_total",
+			Help: "Number of retried requests.",
+		}, []string{"operation", "registry"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "rlt_errors_total",
+			Help: "Number of failed operations by error category.",
+		}, []string{"operation", "category", "registry"}),
+		active: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "rlt_active_instances",
+			Help: "Number of active test instances.",
+		}),
+	}
+	e.registry.MustRegister(
+		e.operations, e.durations, e.bytes, e.retries, e.errors, e.active,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return e
+}
+
+// Handler returns the HTTP handler serving the metrics.
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+// Started counts an instance as active. It does nothing on a nil exporter,
+// i.e. when the metrics are disabled.
+func (e *Exporter) Started() {
+	if e == nil {
+		return
+	}
+	e.active.Inc()
+}
+
+// Write observes the operations of the record and counts its instance as
+// no longer active.
+func (e *Exporter) Write(r result.Record) error {
+	e.active.Dec()
+	if len(r.Fetches) == 0 {
+		// the operation of the record itself, e.g. an auth exchange
+		failed := r.SuccessCount < r.TotalCount
+		e.observe(r.Operation, r.Duration, r.Size, r.RetryCount, lastStatus(r.Requests), failed)
+		for category, n := range r.Errors {
+			e.errors.WithLabelValues(r.Operation, category, e.registryDomain).Add(float64(n))
+		}
+		return nil
+	}
+	for _, f := range r.Fetches {
+		e.observe(f.Kind, f.Duration, f.Size, f.Retries, lastStatus(f.Requests), f.Err != nil)
+		if f.Category != "" {
+			e.errors.WithLabelValues(f.Kind, f.Category, e.registryDomain).Inc()
+		}
+	}
+	return nil
+}
+
+// Close does nothing, the metrics are served until the server is closed.
+func (e *Exporter) Close() error {
+	return nil
+}
+
+// observe observes a completed operation.
+func (e *Exporter) observe(operation string, duration time.Duration, size int64, retries int64, status int, failed bool) {
+	class := statusClass(status, failed)
+	e.operations.WithLabelValues(operation, class, e.registryDomain).Inc()
+	e.durations.WithLabelValues(operation, class, e.registryDomain).Observe(duration.Seconds())
+	if size > 0 {
+		e.bytes.WithLabelValues(operation, e.registryDomain).Add(float64(size))
+	}
+	if retries > 0 {
+		e.retries.WithLabelValues(operation, e.registryDomain).Add(float64(retries))
+	}
+}
+
+// lastStatus returns the status code of the last request, 0 if it received no response.
+func lastStatus(timings []trace.Timing) int {
+	if len(timings) == 0 {
+		return 0
+	}
+	return timings[len(timings)-1].Status
+}
+
+// statusClass returns the class of the status code of an operation, e.g.
+// 2xx, or "error" if the operation failed without an error status code.
+func statusClass(status int, failed bool) string {
+	if status == 0 || (failed && status < 400) {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/internal/trace"
+)
+
+func TestExporter(t *testing.T) {
+	e := New("registry.example.com")
+	e.Started()
+	e.Started()
+	e.Write(result.Record{
+		Operation: result.OperationPull,
+		Fetches: []result.Fetch{
+			{Kind: result.FetchManifest, Duration: 10 * time.Millisecond, Size: 512, Requests: []trace.Timing{{Status: 200}}},
+			{Kind: result.FetchBlob, Duration: time.Second, Retries: 2, Err: errors.New("throttled"), Category: "http_429", Requests: []trace.Timing{{Status: 429}}},
+			{Kind: result.FetchBlob, Duration: time.Second, Err: errors.New("refused"), Category: "connection_refused", Requests: []trace.Timing{{}}},
+		},
+	})
+
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+
+	for _, want := range []string{
+		`rlt_active_instances 1`,
+		`rlt_operations_total{operation="manifest",registry="registry.example.com",status_class="2xx"} 1`,
+		`rlt_operations_total{operation="blob",registry="registry.example.com",status_class="4xx"} 1`,
+		`rlt_operations_total{operation="blob",registry="registry.example.com",status_class="error"} 1`,
+		`rlt_operation_duration_seconds_count{operation="blob",registry="registry.example.com",status_class="4xx"} 1`,
+		`rlt_downloaded_bytes_total{operation="manifest",registry="registry.example.com"} 512`,
+		`rlt_retries_total{operation="blob",registry="registry.example.com"} 2`,
+		`rlt_errors_total{category="http_429",operation="blob",registry="registry.example.com"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+}
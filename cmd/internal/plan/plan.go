@@ -0,0 +1,108 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/schedule"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/selector"
+)
+
+// Entry is a scheduled instance of a plan.
+type Entry struct {
+	// Instance is the index of the instance.
+	Instance int `json:"instance"`
+	// StartOffset is the time the instance starts at, relative to the start of the run.
+	StartOffset time.Duration `json:"start_offset_ns"`
+	// Image is the name of the asset pulled by the instance.
+	Image string `json:"image"`
+}
+
+// Plan is the full schedule of a pull run, which can be saved and replayed.
+type Plan struct {
+	// Seed is the seed the images were picked with.
+	Seed    int64   `json:"seed"`
+	Entries []Entry `json:"entries"`
+}
+
+// Generate generates a plan of at most count instances starting as scheduled
+// by the arrival. The images are picked by the selector. All the random choices are
+// made using a random source seeded by seed.
+func Generate(count int, arrival schedule.Arrival, seed int64, assets []asset.Asset, s selector.Selector) *Plan {
+	r := rand.New(rand.NewSource(seed))
+	offsets := arrival.Offsets(count, r)
+	p := &Plan{
+		Seed:    seed,
+		Entries: make([]Entry, len(offsets)),
+	}
+	for i := range p.Entries {
+		p.Entries[i] = Entry{
+			Instance:    i,
+			StartOffset: offsets[i],
+			Image:       assets[s.Select(r)].Name,
+		}
+	}
+	return p
+}
+
+// Offsets returns the start offsets of the entries.
+func (p *Plan) Offsets() []time.Duration {
+	offsets := make([]time.Duration, len(p.Entries))
+	for i, e := range p.Entries {
+		offsets[i] = e.StartOffset
+	}
+	return offsets
+}
+
+// Resolve returns the asset pulled by each entry of the plan.
+func (p *Plan) Resolve(assets []asset.Asset) ([]asset.Asset, error) {
+	byName := make(map[string]asset.Asset, len(assets))
+	for _, a := range assets {
+		byName[a.Name] = a
+	}
+	resolved := make([]asset.Asset, len(p.Entries))
+	for i, e := range p.Entries {
+		a, ok := byName[e.Image]
+		if !ok {
+			return nil, fmt.Errorf("image %q of instance %d not found in the assets", e.Image, e.Instance)
+		}
+		resolved[i] = a
+	}
+	return resolved, nil
+}
+
+// Write writes the plan as JSON.
+func (p *Plan) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// Read reads a plan written by Write.
+func Read(path string) (*Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	if len(p.Entries) == 0 {
+		return nil, fmt.Errorf("plan %s has no entries", path)
+	}
+	for i, e := range p.Entries {
+		if e.StartOffset < 0 {
+			return nil, fmt.Errorf("instance %d of plan %s has a negative start offset", e.Instance, path)
+		}
+		if i > 0 && e.StartOffset < p.Entries[i-1].StartOffset {
+			return nil, fmt.Errorf("entries of plan %s are not sorted by start offset", path)
+		}
+	}
+	return &p, nil
+}
@@ -0,0 +1,70 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/schedule"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/selector"
+)
+
+func TestGenerate(t *testing.T) {
+	assets := []asset.Asset{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	s, err := selector.New("uniform", assets, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Generate(5, schedule.Burst{Size: 2, Interval: time.Second}, 42, assets, s)
+	wantOffsets := []time.Duration{0, 0, time.Second, time.Second, 2 * time.Second}
+	for i, e := range p.Entries {
+		if e.Instance != i {
+			t.Errorf("Generate() entry %d instance = %d", i, e.Instance)
+		}
+		if e.StartOffset != wantOffsets[i] {
+			t.Errorf("Generate() entry %d start offset = %v, want %v", i, e.StartOffset, wantOffsets[i])
+		}
+	}
+	if again := Generate(5, schedule.Burst{Size: 2, Interval: time.Second}, 42, assets, s); !reflect.DeepEqual(p, again) {
+		t.Errorf("Generate() with the same seed = %+v, want %+v", again, p)
+	}
+	for _, e := range Generate(3, schedule.Burst{}, 42, assets, s).Entries {
+		if e.StartOffset != 0 {
+			t.Errorf("Generate() without batching start offset = %v, want 0", e.StartOffset)
+		}
+	}
+
+	// round trip
+	path := filepath.Join(t.TempDir(), "plan.json")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("Read() = %+v, want %+v", got, p)
+	}
+	resolved, err := got.Resolve(assets)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	for i, a := range resolved {
+		if a.Name != p.Entries[i].Image {
+			t.Errorf("Resolve() asset %d = %q, want %q", i, a.Name, p.Entries[i].Image)
+		}
+	}
+	if _, err := got.Resolve([]asset.Asset{{Name: "other"}}); err == nil {
+		t.Error("Resolve() with missing assets succeeded")
+	}
+}
@@ -0,0 +1,290 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/image"
+)
+
+// Docker media types which are not defined in the OCI image spec.
+const (
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// errNoMatchingPlatform is returned when an index has no manifest for the requested platform.
+var errNoMatchingPlatform = errors.New("no manifest matches the requested platform")
+
+// Generator generates image.Data JSON files from the repositories of a registry.
+type Generator struct {
+	// Registry is the registry domain written into the generated references.
+	Registry string
+	// OutputDir is the directory the JSON files are written into.
+	OutputDir string
+	// TagLimit is the maximum number of tags processed per repository. 0 means no limit.
+	TagLimit int
+	// TagFilter, if set, only keeps the tags matching the expression.
+	TagFilter *regexp.Regexp
+	// Platform is the platform resolved from image indexes.
+	Platform ocispec.Platform
+	// PlainHTTP forces the registry to be accessed via HTTP.
+	PlainHTTP bool
+	// Log receives progress messages.
+	Log io.Writer
+}
+
+// Run processes all the repositories and returns the number of generated files.
+// Failed repositories and tags are logged and skipped; an error summarizing the
+// failures is returned once all the repositories are processed.
+func (g *Generator) Run(ctx context.Context, repositories []string) (int, error) {
+	if err := os.MkdirAll(g.OutputDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var generated, failed int
+	for _, name := range repositories {
+		if err := ctx.Err(); err != nil {
+			return generated, err
+		}
+		g.logf("Processing repository: %s\n", name)
+		repo, err := g.repository(name)
+		if err != nil {
+			g.logf("Error creating repository %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		tags, err := g.listTags(ctx, repo)
+		if err != nil {
+			g.logf("Error listing tags for %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		if len(tags) == 0 {
+			g.logf("No tags found for %s\n", name)
+			continue
+		}
+		for _, tag := range tags {
+			path, err := g.generate(ctx, repo, name, tag)
+			if err != nil {
+				g.logf("Error generating %s:%s: %v\n", name, tag, err)
+				failed++
+				continue
+			}
+			g.logf("Generated %s\n", path)
+			generated++
+		}
+	}
+
+	if failed > 0 {
+		return generated, fmt.Errorf("%d repositories or tags failed to be processed", failed)
+	}
+	return generated, nil
+}
+
+// repository creates a remote repository client for the given repository name.
+func (g *Generator) repository(name string) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(g.Registry + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	repo.PlainHTTP = g.PlainHTTP
+	repo.Client = &auth.Client{
+		Cache:  auth.NewCache(),
+		Client: http.DefaultClient,
+	}
+	return repo, nil
+}
+
+// listTags lists the tags of the repository, applying the filter and the limit.
+func (g *Generator) listTags(ctx context.Context, repo *remote.Repository) ([]string, error) {
+	var tags []string
+	errLimitReached := errors.New("tag limit reached")
+	err := repo.Tags(ctx, "", func(page []string) error {
+		for _, tag := range page {
+			if g.TagFilter != nil && !g.TagFilter.MatchString(tag) {
+				continue
+			}
+			tags = append(tags, tag)
+			if g.TagLimit > 0 && len(tags) >= g.TagLimit {
+				return errLimitReached
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimitReached) {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// generate resolves the image of the given tag and writes its JSON file.
+func (g *Generator) generate(ctx context.Context, repo *remote.Repository, name string, tag string) (string, error) {
+	desc, manifest, err := g.resolveManifest(ctx, repo, tag)
+	if err != nil {
+		return "", err
+	}
+
+	reference := g.Registry + "/" + name
+	data := image.Data{
+		Manifest: reference + "@" + desc.Digest.String(),
+	}
+	for _, blob := range append([]ocispec.Descriptor{manifest.Config}, manifest.Layers...) {
+		// skip empty or missing blobs
+		if blob.Digest == "" || blob.Size <= 0 {
+			continue
+		}
+		data.Blobs = append(data.Blobs, reference+"@"+blob.Digest.String())
+		data.Size += int(blob.Size)
+	}
+
+	path := filepath.Join(g.OutputDir, FileName(name, tag))
+	b, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// resolveManifest fetches the image manifest of the tag. If the tag points to an
+// index, the manifest matching the requested platform is fetched instead.
+func (g *Generator) resolveManifest(ctx context.Context, repo *remote.Repository, tag string) (ocispec.Descriptor, ocispec.Manifest, error) {
+	desc, b, err := fetch(ctx, repo, tag)
+	if err != nil {
+		return ocispec.Descriptor{}, ocispec.Manifest{}, err
+	}
+
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, mediaTypeDockerManifestList:
+		var index ocispec.Index
+		if err := json.Unmarshal(b, &index); err != nil {
+			return ocispec.Descriptor{}, ocispec.Manifest{}, fmt.Errorf("failed to parse index: %w", err)
+		}
+		matched, ok := MatchPlatform(index.Manifests, g.Platform)
+		if !ok {
+			return ocispec.Descriptor{}, ocispec.Manifest{}, fmt.Errorf("%w: %s", errNoMatchingPlatform, FormatPlatform(g.Platform))
+		}
+		if desc, b, err = fetch(ctx, repo, matched.Digest.String()); err != nil {
+			return ocispec.Descriptor{}, ocispec.Manifest{}, err
+		}
+	case ocispec.MediaTypeImageManifest, mediaTypeDockerManifest:
+	default:
+		return ocispec.Descriptor{}, ocispec.Manifest{}, fmt.Errorf("unsupported media type %q", desc.MediaType)
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return ocispec.Descriptor{}, ocispec.Manifest{}, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return desc, manifest, nil
+}
+
+// fetch fetches and verifies the manifest content of the reference.
+func fetch(ctx context.Context, repo *remote.Repository, reference string) (ocispec.Descriptor, []byte, error) {
+	desc, rc, err := repo.Manifests().FetchReference(ctx, reference)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	defer rc.Close()
+	b, err := content.ReadAll(rc, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	return desc, b, nil
+}
+
+// MatchPlatform returns the first manifest matching the platform.
+// Variant and OS version are only compared when they are requested.
+func MatchPlatform(manifests []ocispec.Descriptor, platform ocispec.Platform) (ocispec.Descriptor, bool) {
+	for _, m := range manifests {
+		if m.Platform == nil {
+			continue
+		}
+		if m.Platform.OS != platform.OS || m.Platform.Architecture != platform.Architecture {
+			continue
+		}
+		if platform.Variant != "" && m.Platform.Variant != platform.Variant {
+			continue
+		}
+		if platform.OSVersion != "" && m.Platform.OSVersion != platform.OSVersion {
+			continue
+		}
+		return m, true
+	}
+	return ocispec.Descriptor{}, false
+}
+
+// ParsePlatform parses a platform in the format <os>/<arch>[/<variant>][:<os_version>].
+func ParsePlatform(input string) (ocispec.Platform, error) {
+	var platform ocispec.Platform
+	input, platform.OSVersion, _ = strings.Cut(input, ":")
+	parts := strings.Split(input, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return ocispec.Platform{}, fmt.Errorf("platform %q should be in the format <os>/<arch>[/<variant>][:<os_version>]", input)
+	}
+	platform.OS = parts[0]
+	platform.Architecture = parts[1]
+	if len(parts) == 3 {
+		if parts[2] == "" {
+			return ocispec.Platform{}, fmt.Errorf("empty variant in platform %q", input)
+		}
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+// FormatPlatform formats the platform in the format accepted by ParsePlatform.
+func FormatPlatform(platform ocispec.Platform) string {
+	s := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		s += "/" + platform.Variant
+	}
+	if platform.OSVersion != "" {
+		s += ":" + platform.OSVersion
+	}
+	return s
+}
+
+// FileName returns the JSON file name of the repository tag, replacing the
+// slashes in the repository name with underscores.
+func FileName(repository string, tag string) string {
+	return strings.ReplaceAll(repository, "/", "_") + ":" + tag + ".json"
+}
+
+// ReadRepositories reads the repository names from a file in the format of
+// prepare/repositories.json.
+func ReadRepositories(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Repositories []string `json:"repositories"`
+	}
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return list.Repositories, nil
+}
+
+func (g *Generator) logf(format string, a ...any) {
+	if g.Log != nil {
+		fmt.Fprintf(g.Log, format, a...)
+	}
+}
@@ -0,0 +1,132 @@
+package prepare
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/image"
+)
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ocispec.Platform
+		wantErr bool
+	}{
+		{input: "linux/amd64", want: ocispec.Platform{OS: "linux", Architecture: "amd64"}},
+		{input: "linux/arm/v7", want: ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{input: "windows/amd64:10.0.17763", want: ocispec.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763"}},
+		{input: "linux", wantErr: true},
+		{input: "linux/", wantErr: true},
+		{input: "linux/arm/", wantErr: true},
+		{input: "linux/arm/v7/extra", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParsePlatform(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePlatform() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratorRun(t *testing.T) {
+	config := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: digest.FromString("config"), Size: 6}
+	layer := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayer, Digest: digest.FromString("layer"), Size: 5}
+	manifest, _ := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	manifestDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifest),
+		Size:      int64(len(manifest)),
+		Platform:  &ocispec.Platform{OS: "linux", Architecture: "amd64"},
+	}
+	index, _ := json.Marshal(ocispec.Index{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{
+			{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromString("arm64"), Size: 1, Platform: &ocispec.Platform{OS: "linux", Architecture: "arm64"}},
+			manifestDesc,
+		},
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve := func(mediaType string, b []byte) {
+			w.Header().Set("Content-Type", mediaType)
+			w.Header().Set("Docker-Content-Digest", digest.FromBytes(b).String())
+			w.Write(b)
+		}
+		switch r.URL.Path {
+		case "/v2/test/app/tags/list":
+			json.NewEncoder(w).Encode(map[string]any{"name": "test/app", "tags": []string{"v1", "v2", "latest", "v3"}})
+		case "/v2/test/app/manifests/v1", "/v2/test/app/manifests/v2":
+			serve(ocispec.MediaTypeImageIndex, index)
+		case "/v2/test/app/manifests/" + manifestDesc.Digest.String():
+			serve(ocispec.MediaTypeImageManifest, manifest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := strings.TrimPrefix(server.URL, "http://")
+	outputDir := t.TempDir()
+	generator := &Generator{
+		Registry:  registry,
+		OutputDir: outputDir,
+		TagLimit:  2,
+		TagFilter: regexp.MustCompile("^v"),
+		Platform:  ocispec.Platform{OS: "linux", Architecture: "amd64"},
+		PlainHTTP: true,
+	}
+	generated, err := generator.Run(context.Background(), []string{"test/app"})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if generated != 2 {
+		t.Fatalf("Run() generated = %d, want 2", generated)
+	}
+
+	b, err := os.ReadFile(filepath.Join(outputDir, "test_app:v2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got image.Data
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := image.Data{
+		Size:     11,
+		Manifest: registry + "/test/app@" + manifestDesc.Digest.String(),
+		Blobs: []string{
+			registry + "/test/app@" + config.Digest.String(),
+			registry + "/test/app@" + layer.Digest.String(),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("generated data = %+v, want %+v", got, want)
+	}
+
+	// failures are reported instead of being swallowed
+	generated, err = generator.Run(context.Background(), []string{"test/missing"})
+	if err == nil || generated != 0 {
+		t.Errorf("Run() = %d, %v, want an error", generated, err)
+	}
+}
@@ -0,0 +1,142 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/stats"
+)
+
+// window is the number of intervals the rolling latency is computed over.
+const window = 10
+
+// bucket aggregates the records completed within an interval.
+type bucket struct {
+	requests int64
+	bytes    int64
+	latency  stats.Histogram
+}
+
+// Display shows the live progress of a run on a single line, refreshed every
+// interval. It implements result.Writer so that it can be fed along with the
+// results output.
+type Display struct {
+	w        io.Writer
+	interval time.Duration
+	start    time.Time
+	started  atomic.Int64
+
+	mu        sync.Mutex
+	completed int64
+	errors    map[string]int64
+	buckets   [window]bucket
+	current   int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a display writing to w every interval. The display is started by Start.
+func New(w io.Writer, interval time.Duration) *Display {
+	return &Display{
+		w:        w,
+		interval: interval,
+		errors:   make(map[string]int64),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts refreshing the display.
+func (d *Display) Start() {
+	d.start = time.Now()
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fmt.Fprintf(d.w, "\r\033[K%s", d.tick())
+			case <-d.stop:
+				// clear the line for the following output
+				fmt.Fprint(d.w, "\r\033[K")
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops refreshing the display and clears it. It does nothing on a nil
+// display, i.e. when the display is disabled.
+func (d *Display) Stop() {
+	if d == nil {
+		return
+	}
+	close(d.stop)
+	<-d.done
+}
+
+// Started counts an instance as started. It does nothing on a nil display.
+func (d *Display) Started() {
+	if d == nil {
+		return
+	}
+	d.started.Add(1)
+}
+
+// Write counts the record as completed.
+func (d *Display) Write(r result.Record) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.completed++
+	b := &d.buckets[d.current]
+	b.requests += max(int64(len(r.Fetches)), 1)
+	b.bytes += r.Size
+	b.latency.Record(r.Duration)
+	for category, n := range r.Errors {
+		d.errors[category] += int64(n)
+	}
+	return nil
+}
+
+// Close does nothing, the display is stopped by Stop.
+func (d *Display) Close() error {
+	return nil
+}
+
+// tick formats the progress line and moves to the next interval.
+func (d *Display) tick() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	last := &d.buckets[d.current]
+	var latency stats.Histogram
+	for i := range d.buckets {
+		latency.Merge(&d.buckets[i].latency)
+	}
+	started := d.started.Load()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] started %d  active %d  completed %d  |  %.1f req/s  %.2f MB/s  |  p95 %s",
+		time.Since(d.start).Round(time.Second), started, started-d.completed, d.completed,
+		float64(last.requests)/d.interval.Seconds(), float64(last.bytes)/1e6/d.interval.Seconds(),
+		stats.FormatDuration(latency.Quantile(0.95)))
+	if len(d.errors) > 0 {
+		var total int64
+		var parts []string
+		for _, category := range stats.ErrorCategories(d.errors) {
+			total += d.errors[category]
+			parts = append(parts, fmt.Sprintf("%s=%d", category, d.errors[category]))
+		}
+		fmt.Fprintf(&sb, "  |  errors %d (%s)", total, strings.Join(parts, " "))
+	}
+
+	d.current = (d.current + 1) % window
+	d.buckets[d.current] = bucket{}
+	return sb.String()
+}
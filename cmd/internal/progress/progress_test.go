@@ -0,0 +1,59 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+)
+
+func TestDisplayTick(t *testing.T) {
+	d := New(io.Discard, time.Second)
+	for range 3 {
+		d.Started()
+	}
+	d.Write(result.Record{
+		Operation: result.OperationPull,
+		Duration:  time.Second,
+		Size:      3_000_000,
+		Fetches:   make([]result.Fetch, 3),
+		Errors:    map[string]int{"http_429": 2},
+	})
+	d.Write(result.Record{Operation: result.OperationPull, Duration: 2 * time.Second, Fetches: make([]result.Fetch, 1)})
+
+	line := d.tick()
+	for _, want := range []string{
+		"started 3  active 1  completed 2",
+		"4.0 req/s  3.00 MB/s",
+		"p95 2s",
+		"errors 2 (http_429=2)",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("tick() = %q, want containing %q", line, want)
+		}
+	}
+
+	// the rates only cover the last interval, the latency the rolling window
+	line = d.tick()
+	if !strings.Contains(line, "0.0 req/s  0.00 MB/s  |  p95 2s") {
+		t.Errorf("tick() = %q, want no rate and the rolling p95", line)
+	}
+}
+
+func TestDisplayStop(t *testing.T) {
+	var buf bytes.Buffer
+	d := New(&buf, time.Hour)
+	d.Start()
+	d.Stop()
+	if got := buf.String(); got != "\r\033[K" {
+		t.Errorf("output = %q, want the line cleared", got)
+	}
+
+	// a nil display is disabled
+	var disabled *Display
+	disabled.Started()
+	disabled.Stop()
+}
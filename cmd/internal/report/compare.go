@@ -0,0 +1,330 @@
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/stats"
+)
+
+// minSamples is the number of samples of each run under which the
+// significance of a change is not tested, the change being deemed not
+// significant.
+const minSamples = 10
+
+// Verdicts of the compared metrics.
+const (
+	VerdictOK             = "ok"
+	VerdictRegression     = "regression"
+	VerdictImprovement    = "improvement"
+	VerdictNotSignificant = "not significant"
+	// VerdictMissing is the verdict of an operation of the baseline missing
+	// from the candidate, counted as a regression.
+	VerdictMissing = "missing"
+	// VerdictNew is the verdict of an operation of the candidate missing from
+	// the baseline.
+	VerdictNew = "new"
+)
+
+// Thresholds are the changes of the metrics from which a candidate run
+// regresses from a baseline run.
+type Thresholds struct {
+	// Latency is the relative increase of the latency percentiles.
+	Latency float64
+	// Throughput is the relative decrease of the operation and byte rates.
+	Throughput float64
+	// ErrorRate is the increase of the error rate, in absolute terms.
+	ErrorRate float64
+	// Significance is the p-value under which a change is significant.
+	Significance float64
+}
+
+// Delta is the change of a metric of an operation between two runs.
+type Delta struct {
+	Operation string
+	Metric    string
+	Baseline  string
+	Candidate string
+	Change    string
+	// PValue is the p-value of the change, NaN if not tested.
+	PValue  float64
+	Verdict string
+}
+
+// Comparison is the comparison of a candidate run with a baseline run.
+type Comparison struct {
+	Deltas []Delta
+}
+
+// run is the aggregate of the results of a run.
+type run struct {
+	elapsed time.Duration
+	// names are the names of the operations, in the order of the summary.
+	names      []string
+	operations map[string]stats.Operation
+	// durations are the durations of the operations by name.
+	durations map[string][]time.Duration
+}
+
+// newRun aggregates the records of a run.
+func newRun(records []result.Record) run {
+	// the timeline of an automatic interval cannot fail
+	r, _ := New(records, 0)
+	run := run{
+		elapsed:    r.Elapsed(),
+		operations: make(map[string]stats.Operation),
+		durations:  make(map[string][]time.Duration),
+	}
+	for _, op := range r.Summary.Operations() {
+		run.names = append(run.names, op.Name)
+		run.operations[op.Name] = op
+	}
+	for _, record := range records {
+		for _, s := range samples(record) {
+			run.durations[s.operation] = append(run.durations[s.operation], s.duration)
+		}
+	}
+	return run
+}
+
+// Compare compares the latencies, throughputs and error rates of the
+// operations of a candidate run with a baseline run.
+func Compare(baseline []result.Record, candidate []result.Record, t Thresholds) *Comparison {
+	base, cand := newRun(baseline), newRun(candidate)
+	c := &Comparison{}
+	names := base.names
+	for _, name := range cand.names {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		b, inBase := base.operations[name]
+		cd, inCand := cand.operations[name]
+		if !inBase || !inCand {
+			v := VerdictMissing
+			if !inBase {
+				v = VerdictNew
+			}
+			c.Deltas = append(c.Deltas, Delta{Operation: name, Metric: "count", Baseline: strconv.FormatInt(b.Count, 10),
+				Candidate: strconv.FormatInt(cd.Count, 10), Change: "-", PValue: math.NaN(), Verdict: v})
+			continue
+		}
+
+		greater, less := mannWhitney(base.durations[name], cand.durations[name])
+		for _, q := range []float64{0.5, 0.9, 0.99} {
+			bq, cq := b.Latency.Quantile(q), cd.Latency.Quantile(q)
+			change := relativeChange(float64(bq), float64(cq))
+			d := Delta{
+				Operation: name,
+				Metric:    fmt.Sprintf("p%g", q*100),
+				Baseline:  stats.FormatDuration(bq),
+				Candidate: stats.FormatDuration(cq),
+				Change:    formatChange(change),
+				PValue:    math.NaN(),
+				Verdict:   VerdictOK,
+			}
+			switch {
+			case change > t.Latency:
+				d.PValue = greater
+				d.Verdict = verdict(greater, t.Significance, VerdictRegression)
+			case change < -t.Latency:
+				d.PValue = less
+				d.Verdict = verdict(less, t.Significance, VerdictImprovement)
+			}
+			c.Deltas = append(c.Deltas, d)
+		}
+
+		if base.elapsed > 0 && cand.elapsed > 0 {
+			type rate struct {
+				metric    string
+				baseline  float64
+				candidate float64
+			}
+			rates := []rate{
+				{"ops/s", float64(b.Count) / base.elapsed.Seconds(), float64(cd.Count) / cand.elapsed.Seconds()},
+			}
+			if name != result.OperationAuth && name != result.FetchToken {
+				rates = append(rates, rate{"MB/s", float64(b.Bytes) / 1e6 / base.elapsed.Seconds(), float64(cd.Bytes) / 1e6 / cand.elapsed.Seconds()})
+			}
+			for _, r := range rates {
+				change := relativeChange(r.baseline, r.candidate)
+				d := Delta{
+					Operation: name,
+					Metric:    r.metric,
+					Baseline:  fmt.Sprintf("%.2f", r.baseline),
+					Candidate: fmt.Sprintf("%.2f", r.candidate),
+					Change:    formatChange(change),
+					PValue:    math.NaN(),
+					Verdict:   VerdictOK,
+				}
+				// a single rate is measured per run, its significance is not tested
+				switch {
+				case change < -t.Throughput:
+					d.Verdict = VerdictRegression
+				case change > t.Throughput:
+					d.Verdict = VerdictImprovement
+				}
+				c.Deltas = append(c.Deltas, d)
+			}
+		}
+
+		bRate, cRate := 1-b.SuccessRate(), 1-cd.SuccessRate()
+		greater, less = twoProportions(b.Count-b.Success, b.Count, cd.Count-cd.Success, cd.Count)
+		d := Delta{
+			Operation: name,
+			Metric:    "error rate",
+			Baseline:  fmt.Sprintf("%.2f%%", bRate*100),
+			Candidate: fmt.Sprintf("%.2f%%", cRate*100),
+			Change:    fmt.Sprintf("%+.2fpp", (cRate-bRate)*100),
+			PValue:    math.NaN(),
+			Verdict:   VerdictOK,
+		}
+		switch {
+		case cRate-bRate > t.ErrorRate:
+			d.PValue = greater
+			d.Verdict = verdict(greater, t.Significance, VerdictRegression)
+		case bRate-cRate > t.ErrorRate:
+			d.PValue = less
+			d.Verdict = verdict(less, t.Significance, VerdictImprovement)
+		}
+		c.Deltas = append(c.Deltas, d)
+	}
+	return c
+}
+
+// Regressions returns the number of regressed metrics, including the
+// operations missing from the candidate.
+func (c *Comparison) Regressions() int {
+	var n int
+	for _, d := range c.Deltas {
+		if d.Verdict == VerdictRegression || d.Verdict == VerdictMissing {
+			n++
+		}
+	}
+	return n
+}
+
+// Table returns the table of the deltas.
+func (c *Comparison) Table() stats.Table {
+	t := stats.Table{
+		Title:  "Comparison of the candidate with the baseline:",
+		Header: []string{"operation", "metric", "baseline", "candidate", "change", "p-value", "verdict"},
+	}
+	for _, d := range c.Deltas {
+		p := "-"
+		if !math.IsNaN(d.PValue) {
+			p = fmt.Sprintf("%.4f", d.PValue)
+		}
+		t.Rows = append(t.Rows, []string{d.Operation, d.Metric, d.Baseline, d.Candidate, d.Change, p, d.Verdict})
+	}
+	return t
+}
+
+// verdict returns the verdict of a change over its threshold with a p-value.
+func verdict(p float64, significance float64, v string) string {
+	if math.IsNaN(p) || p >= significance {
+		return VerdictNotSignificant
+	}
+	return v
+}
+
+// relativeChange returns the change from a to b relative to a.
+func relativeChange(a float64, b float64) float64 {
+	if a == 0 {
+		if b == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return (b - a) / a
+}
+
+// formatChange formats a relative change as a percentage.
+func formatChange(change float64) string {
+	if math.IsInf(change, 0) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", change*100)
+}
+
+// mannWhitney returns the one-sided p-values of the Mann-Whitney U test that
+// the values of b are greater, and less, than the values of a, with the normal
+// approximation corrected for ties. The p-values are NaN if either sample
+// has fewer than minSamples values.
+func mannWhitney(a []time.Duration, b []time.Duration) (greater float64, less float64) {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if len(a) < minSamples || len(b) < minSamples {
+		return math.NaN(), math.NaN()
+	}
+	type value struct {
+		v     time.Duration
+		fromB bool
+	}
+	values := make([]value, 0, len(a)+len(b))
+	for _, v := range a {
+		values = append(values, value{v: v})
+	}
+	for _, v := range b {
+		values = append(values, value{v: v, fromB: true})
+	}
+	slices.SortFunc(values, func(x, y value) int { return cmp.Compare(x.v, y.v) })
+
+	// rank the values, ties getting their average rank
+	var rankSumB, tieTerm float64
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].v == values[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].fromB {
+				rankSumB += rank
+			}
+		}
+		ties := float64(j - i)
+		tieTerm += ties*ties*ties - ties
+		i = j
+	}
+
+	u := rankSumB - n2*(n2+1)/2
+	n := n1 + n2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return 1, 1
+	}
+	mean := n1 * n2 / 2
+	// continuity correction
+	zGreater := (u - mean - 0.5) / sigma
+	zLess := (mean - u - 0.5) / sigma
+	return upperTail(zGreater), upperTail(zLess)
+}
+
+// twoProportions returns the one-sided p-values of the two-proportion z-test
+// that the proportion x2/n2 is greater, and less, than x1/n1. The p-values are
+// NaN if either sample has fewer than minSamples values.
+func twoProportions(x1 int64, n1 int64, x2 int64, n2 int64) (greater float64, less float64) {
+	if n1 < minSamples || n2 < minSamples {
+		return math.NaN(), math.NaN()
+	}
+	p1, p2 := float64(x1)/float64(n1), float64(x2)/float64(n2)
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 1, 1
+	}
+	z := (p2 - p1) / se
+	return upperTail(z), upperTail(-z)
+}
+
+// upperTail returns the probability that a standard normal variable exceeds z.
+func upperTail(z float64) float64 {
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
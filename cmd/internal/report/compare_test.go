@@ -0,0 +1,91 @@
+package report
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+)
+
+// blobs returns n blob fetches spread over 10 seconds, lasting base plus up
+// to 10% of it, of which the first failed ones fail.
+func blobs(n int, base time.Duration, failed int) []result.Record {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := make([]result.Record, n)
+	for i := range records {
+		var err error
+		if i < failed {
+			err = errors.New("timeout")
+		}
+		jitter := base / 10 * time.Duration(i) / time.Duration(n)
+		records[i] = fetchRecord(result.FetchBlob, "library/alpine", start.Add(10*time.Second*time.Duration(i)/time.Duration(n)), base+jitter, 1e6, err)
+	}
+	return records
+}
+
+func TestCompare(t *testing.T) {
+	thresholds := Thresholds{Latency: 0.1, Throughput: 0.1, ErrorRate: 0.005, Significance: 0.05}
+	verdicts := func(c *Comparison) map[string]string {
+		m := make(map[string]string)
+		for _, d := range c.Deltas {
+			m[d.Metric] = d.Verdict
+		}
+		return m
+	}
+
+	same := Compare(blobs(100, 100*time.Millisecond, 0), blobs(100, 100*time.Millisecond, 0), thresholds)
+	if n := same.Regressions(); n != 0 {
+		t.Errorf("Regressions() of the same runs = %d, want 0", n)
+	}
+
+	slower := verdicts(Compare(blobs(100, 100*time.Millisecond, 0), blobs(100, 200*time.Millisecond, 20), thresholds))
+	for metric, want := range map[string]string{
+		"p50":        VerdictRegression,
+		"p99":        VerdictRegression,
+		"ops/s":      VerdictOK,
+		"error rate": VerdictRegression,
+	} {
+		if got := slower[metric]; got != want {
+			t.Errorf("verdict of %s = %q, want %q", metric, got, want)
+		}
+	}
+
+	faster := verdicts(Compare(blobs(100, 200*time.Millisecond, 0), blobs(100, 100*time.Millisecond, 0), thresholds))
+	if got := faster["p50"]; got != VerdictImprovement {
+		t.Errorf("verdict of p50 = %q, want %q", got, VerdictImprovement)
+	}
+
+	// the operations missing from the candidate regress, the new ones do not
+	var auth []result.Record
+	for range 20 {
+		auth = append(auth, result.Record{Operation: result.OperationAuth, Duration: time.Millisecond, TotalCount: 1, SuccessCount: 1})
+	}
+	missing := Compare(append(blobs(100, 100*time.Millisecond, 0), auth...), blobs(100, 100*time.Millisecond, 0), thresholds)
+	if got := verdicts(missing)["count"]; got != VerdictMissing || missing.Regressions() != 1 {
+		t.Errorf("verdict of the missing auth = %q with %d regressions, want %q with 1", got, missing.Regressions(), VerdictMissing)
+	}
+	added := Compare(blobs(100, 100*time.Millisecond, 0), append(blobs(100, 100*time.Millisecond, 0), auth...), thresholds)
+	if got := verdicts(added)["count"]; got != VerdictNew || added.Regressions() != 0 {
+		t.Errorf("verdict of the new auth = %q with %d regressions, want %q with 0", got, added.Regressions(), VerdictNew)
+	}
+
+	// too few samples to be significant
+	few := verdicts(Compare(blobs(5, 100*time.Millisecond, 0), blobs(5, 200*time.Millisecond, 0), thresholds))
+	if got := few["p50"]; got != VerdictNotSignificant {
+		t.Errorf("verdict of p50 with few samples = %q, want %q", got, VerdictNotSignificant)
+	}
+}
+
+func TestMannWhitney(t *testing.T) {
+	a := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	greater, less := mannWhitney(a, a)
+	if math.Abs(greater-less) > 1e-9 || greater < 0.4 {
+		t.Errorf("mannWhitney() of the same values = %v, %v, want equal and not significant", greater, less)
+	}
+	b := []time.Duration{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+	if greater, _ := mannWhitney(a, b); greater >= 0.001 {
+		t.Errorf("mannWhitney() greater p-value = %v, want < 0.001", greater)
+	}
+}
@@ -0,0 +1,226 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/stats"
+)
+
+// Dimensions of the charts, in pixels.
+const (
+	chartWidth  = 900
+	chartHeight = 260
+	marginLeft  = 70
+	marginRight = 20
+	marginTop   = 10
+	marginBot   = 30
+)
+
+// colors are the colors of the series of a chart.
+var colors = []string{"#1f77b4", "#ff7f0e", "#d62728", "#2ca02c", "#9467bd", "#8c564b"}
+
+//go:embed report.html.tmpl
+var htmlTemplate string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"heading": func(title string) string { return strings.TrimSuffix(title, ":") },
+	"points": func(segment []xy) string {
+		points := make([]string, len(segment))
+		for i, p := range segment {
+			points[i] = fmt.Sprintf("%g,%g", p.X, p.Y)
+		}
+		return strings.Join(points, " ")
+	},
+}).Parse(htmlTemplate))
+
+// chart is a line chart of the timeline.
+type chart struct {
+	Title  string
+	Width  int
+	Height int
+	// Left, Right and Bottom bound the plot area.
+	Left   int
+	Right  int
+	Bottom int
+	Series []series
+	XTicks []tick
+	YTicks []tick
+}
+
+// series is a line of a chart, split into segments where values are missing.
+type series struct {
+	Name     string
+	Color    string
+	Segments [][]xy
+}
+
+// xy is the position of a value on a chart.
+type xy struct {
+	X float64
+	Y float64
+}
+
+// tick is a labeled position on an axis.
+type tick struct {
+	Pos   float64
+	Label string
+}
+
+// point is a value of a series, missing if not ok.
+type point struct {
+	value float64
+	ok    bool
+}
+
+// WriteHTML writes the report as a self-contained HTML page, titled after
+// the sources of the results.
+func (r *Report) WriteHTML(w io.Writer, title string) error {
+	data := struct {
+		Title     string
+		Generated string
+		Start     string
+		End       string
+		Tables    []stats.Table
+		Charts    []chart
+	}{
+		Title:     title,
+		Generated: time.Now().Format(time.RFC3339),
+		Tables:    r.Tables(),
+		Charts:    r.charts(),
+	}
+	if !r.Start.IsZero() {
+		data.Start = r.Start.Format(time.RFC3339)
+		data.End = r.End.Format(time.RFC3339)
+	}
+	return reportTemplate.Execute(w, data)
+}
+
+// charts returns the latency charts of the operations and the throughput
+// charts of the timeline.
+func (r *Report) charts() []chart {
+	if len(r.Timeline) == 0 {
+		return nil
+	}
+	var charts []chart
+	quantiles := []float64{0.5, 0.9, 0.99}
+	for _, op := range r.Operations {
+		names := make([]string, len(quantiles))
+		values := make([][]point, len(quantiles))
+		for i, q := range quantiles {
+			names[i] = fmt.Sprintf("p%g", q*100)
+			for _, b := range r.Timeline {
+				h, ok := b.Latency[op]
+				if !ok {
+					values[i] = append(values[i], point{})
+					continue
+				}
+				values[i] = append(values[i], point{h.Quantile(q).Seconds() * 1000, true})
+			}
+		}
+		charts = append(charts, r.chart(fmt.Sprintf("%s latency (ms)", op), names, values))
+	}
+
+	seconds := r.Interval.Seconds()
+	var mbs, completed, errors []point
+	for _, b := range r.Timeline {
+		mbs = append(mbs, point{float64(b.Bytes) / 1e6 / seconds, true})
+		completed = append(completed, point{float64(b.Count) / seconds, true})
+		errors = append(errors, point{float64(b.Errors) / seconds, true})
+	}
+	charts = append(charts,
+		r.chart("Throughput (MB/s)", []string{"MB/s"}, [][]point{mbs}),
+		r.chart("Operations per second", []string{"completed", "failed"}, [][]point{completed, errors}),
+	)
+	return charts
+}
+
+// chart returns a line chart of the named series of values per interval of
+// the timeline.
+func (r *Report) chart(title string, names []string, values [][]point) chart {
+	c := chart{
+		Title:  title,
+		Width:  chartWidth,
+		Height: chartHeight,
+		Left:   marginLeft,
+		Right:  chartWidth - marginRight,
+		Bottom: chartHeight - marginBot,
+	}
+	var highest float64
+	for _, points := range values {
+		for _, p := range points {
+			if p.ok {
+				highest = max(highest, p.value)
+			}
+		}
+	}
+	top, step := 1.0, 1.0
+	if highest > 0 {
+		step = niceStep(highest / 4)
+		top = step * math.Ceil(highest/step)
+	}
+	plotWidth := float64(chartWidth - marginLeft - marginRight)
+	plotHeight := float64(chartHeight - marginTop - marginBot)
+	x := func(i int) float64 {
+		if len(r.Timeline) == 1 {
+			return marginLeft
+		}
+		return marginLeft + plotWidth*float64(i)/float64(len(r.Timeline)-1)
+	}
+	y := func(v float64) float64 {
+		return marginTop + plotHeight*(1-v/top)
+	}
+
+	for v := 0.0; v <= top+step/2; v += step {
+		c.YTicks = append(c.YTicks, tick{y(v), formatValue(v)})
+	}
+	every := max(1, len(r.Timeline)/8)
+	for i := 0; i < len(r.Timeline); i += every {
+		c.XTicks = append(c.XTicks, tick{x(i), (time.Duration(i) * r.Interval).String()})
+	}
+
+	for i, name := range names {
+		s := series{Name: name, Color: colors[i%len(colors)]}
+		var segment []xy
+		for j, p := range values[i] {
+			if !p.ok {
+				if len(segment) > 0 {
+					s.Segments = append(s.Segments, segment)
+					segment = nil
+				}
+				continue
+			}
+			segment = append(segment, xy{math.Round(x(j)*10) / 10, math.Round(y(p.value)*10) / 10})
+		}
+		if len(segment) > 0 {
+			s.Segments = append(s.Segments, segment)
+		}
+		c.Series = append(c.Series, s)
+	}
+	return c
+}
+
+// niceStep returns the smallest of 1, 2 or 5 times a power of 10 not less
+// than v, which must be positive.
+func niceStep(v float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if step := m * magnitude; step >= v {
+			return step
+		}
+	}
+	return 10 * magnitude
+}
+
+// formatValue formats a value of an axis.
+func formatValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%g", math.Round(v*1000)/1000)
+}
@@ -0,0 +1,305 @@
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/stats"
+)
+
+// maxBuckets is the number of intervals the timeline is split into at most,
+// when the interval is picked automatically.
+const maxBuckets = 120
+
+// maxIntervals is the number of intervals the timeline may be split into at
+// most with a given interval.
+const maxIntervals = 100 * maxBuckets
+
+// intervals are the intervals the timeline may be split into.
+var intervals = []time.Duration{
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour,
+}
+
+// sizeClass is a class of content sizes of the size breakdown.
+type sizeClass struct {
+	name string
+	// max is the exclusive upper bound of the sizes of the class.
+	max int64
+}
+
+// sizeClasses are the classes of the size breakdown.
+var sizeClasses = []sizeClass{
+	{"< 1MB", 1e6},
+	{"1MB - 10MB", 1e7},
+	{"10MB - 100MB", 1e8},
+	{"100MB - 1GB", 1e9},
+	{">= 1GB", math.MaxInt64},
+}
+
+// Group aggregates the operations of a breakdown, e.g. the fetches of a
+// repository.
+type Group struct {
+	Name    string
+	Count   int64
+	Success int64
+	Bytes   int64
+	// Busy is the total duration of the operations, the transfer rate being
+	// Bytes over Busy.
+	Busy    time.Duration
+	Latency stats.Histogram
+}
+
+// Bucket aggregates the operations completed within an interval of the timeline.
+type Bucket struct {
+	Count  int64
+	Errors int64
+	Bytes  int64
+	// Latency are the latencies of the operations by name.
+	Latency map[string]*stats.Histogram
+}
+
+// Report aggregates saved results.
+type Report struct {
+	Summary *stats.Summary
+	// Start and End bound the operations, zero if the results have no timestamp.
+	Start time.Time
+	End   time.Time
+	// Repositories and Sizes break down the pulls by repository, or by asset
+	// if the results are not detailed, and by size.
+	Repositories []*Group
+	Sizes        []*Group
+	// Timeline is the activity of the run per Interval since Start.
+	Interval time.Duration
+	Timeline []Bucket
+	// Operations are the names of the operations of the timeline.
+	Operations []string
+}
+
+// sample is an operation of the results.
+type sample struct {
+	operation string
+	// group is the repository or asset of pulls, empty for auth exchanges and
+	// tokens.
+	group    string
+	start    time.Time
+	duration time.Duration
+	size     int64
+	failed   bool
+}
+
+// samples returns the operations of a record: its fetches if any, or the
+// record itself.
+func samples(r result.Record) []sample {
+	if len(r.Fetches) == 0 {
+		s := sample{
+			operation: r.Operation,
+			start:     r.Timestamp,
+			duration:  r.Duration,
+			size:      r.Size,
+			failed:    r.SuccessCount < r.TotalCount,
+		}
+		if r.Operation != result.OperationAuth {
+			s.group = r.Name
+		}
+		return []sample{s}
+	}
+	ss := make([]sample, len(r.Fetches))
+	for i, f := range r.Fetches {
+		ss[i] = sample{
+			operation: f.Kind,
+			group:     f.Repository,
+			start:     f.Timestamp,
+			duration:  f.Duration,
+			size:      f.Size,
+			failed:    f.Err != nil,
+		}
+		if f.Kind == result.FetchToken {
+			// the breakdowns are of the pulled content
+			ss[i].group = ""
+		}
+	}
+	return ss
+}
+
+// New returns the report of the records, with a timeline split into
+// intervals, picked according to the span of the records if 0. It fails if
+// the interval splits the timeline into more than maxIntervals intervals.
+func New(records []result.Record, interval time.Duration) (*Report, error) {
+	r := &Report{Summary: stats.NewSummary()}
+	var all []sample
+	for _, record := range records {
+		r.Summary.Write(record)
+		all = append(all, samples(record)...)
+	}
+
+	repositories := make(map[string]*Group)
+	sizes := make([]*Group, len(sizeClasses))
+	operations := make(map[string]bool)
+	for _, s := range all {
+		if !s.start.IsZero() {
+			if r.Start.IsZero() || s.start.Before(r.Start) {
+				r.Start = s.start
+			}
+			if end := s.start.Add(s.duration); end.After(r.End) {
+				r.End = end
+			}
+		}
+		operations[s.operation] = true
+		if s.group == "" {
+			continue
+		}
+		g, ok := repositories[s.group]
+		if !ok {
+			g = &Group{Name: s.group}
+			repositories[s.group] = g
+		}
+		g.add(s)
+		i := slices.IndexFunc(sizeClasses, func(c sizeClass) bool { return s.size < c.max })
+		if sizes[i] == nil {
+			sizes[i] = &Group{Name: sizeClasses[i].name}
+		}
+		sizes[i].add(s)
+	}
+
+	for _, g := range repositories {
+		r.Repositories = append(r.Repositories, g)
+	}
+	slices.SortFunc(r.Repositories, func(a, b *Group) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Name, b.Name))
+	})
+	for _, g := range sizes {
+		if g != nil {
+			r.Sizes = append(r.Sizes, g)
+		}
+	}
+	for _, op := range r.Summary.Operations() {
+		if operations[op.Name] {
+			r.Operations = append(r.Operations, op.Name)
+		}
+	}
+	if err := r.buildTimeline(all, interval); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// add adds an operation to the group.
+func (g *Group) add(s sample) {
+	g.Count++
+	if !s.failed {
+		g.Success++
+	}
+	g.Bytes += s.size
+	g.Busy += s.duration
+	g.Latency.Record(s.duration)
+}
+
+// buildTimeline buckets the operations by interval of completion. It fails if
+// the interval is negative or too small for the span of the records.
+func (r *Report) buildTimeline(all []sample, interval time.Duration) error {
+	span := r.End.Sub(r.Start)
+	if r.Start.IsZero() {
+		return nil
+	}
+	switch {
+	case interval < 0:
+		return fmt.Errorf("interval %v must not be negative", interval)
+	case interval > 0 && span/interval >= maxIntervals:
+		return fmt.Errorf("interval %v splits the %v span of the results into more than %d intervals", interval, span, maxIntervals)
+	case interval == 0:
+		interval = intervals[len(intervals)-1]
+		for _, i := range intervals {
+			if span/i < maxBuckets {
+				interval = i
+				break
+			}
+		}
+	}
+	r.Interval = interval
+	r.Timeline = make([]Bucket, span/interval+1)
+	for _, s := range all {
+		if s.start.IsZero() {
+			continue
+		}
+		b := &r.Timeline[s.start.Add(s.duration).Sub(r.Start)/interval]
+		b.Count++
+		if s.failed {
+			b.Errors++
+		}
+		b.Bytes += s.size
+		if b.Latency == nil {
+			b.Latency = make(map[string]*stats.Histogram)
+		}
+		h, ok := b.Latency[s.operation]
+		if !ok {
+			h = &stats.Histogram{}
+			b.Latency[s.operation] = h
+		}
+		h.Record(s.duration)
+	}
+	return nil
+}
+
+// Elapsed returns the span of the operations.
+func (r *Report) Elapsed() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// Tables returns the tables of the report: the summary of the operations,
+// the request phases, the errors, if any, and the breakdowns.
+func (r *Report) Tables() []stats.Table {
+	tables := r.Summary.Tables(r.Elapsed())
+	if len(r.Repositories) > 0 {
+		tables = append(tables, groupTable("By repository:", "repository", r.Repositories))
+	}
+	if len(r.Sizes) > 0 {
+		tables = append(tables, groupTable("By size:", "size", r.Sizes))
+	}
+	return tables
+}
+
+// Print prints the tables of the report.
+func (r *Report) Print(w io.Writer) error {
+	if !r.Start.IsZero() {
+		fmt.Fprintf(w, "Results from %s to %s\n", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339))
+	}
+	for _, t := range r.Tables() {
+		if err := t.Print(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupTable returns the table of a breakdown.
+func groupTable(title string, name string, groups []*Group) stats.Table {
+	t := stats.Table{
+		Title:  title,
+		Header: []string{name, "count", "success", "MB", "MB/s", "p50", "p90", "p99", "max"},
+	}
+	for _, g := range groups {
+		rate := "-"
+		if g.Busy > 0 {
+			rate = fmt.Sprintf("%.2f", float64(g.Bytes)/1e6/g.Busy.Seconds())
+		}
+		t.Rows = append(t.Rows, []string{
+			g.Name,
+			strconv.FormatInt(g.Count, 10),
+			fmt.Sprintf("%.2f%%", float64(g.Success)/float64(g.Count)*100),
+			fmt.Sprintf("%.2f", float64(g.Bytes)/1e6),
+			rate,
+			stats.FormatDuration(g.Latency.Quantile(0.5)),
+			stats.FormatDuration(g.Latency.Quantile(0.9)),
+			stats.FormatDuration(g.Latency.Quantile(0.99)),
+			stats.FormatDuration(g.Latency.Max()),
+		})
+	}
+	return t
+}
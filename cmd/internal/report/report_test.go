@@ -0,0 +1,123 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+)
+
+func fetchRecord(kind string, repository string, start time.Time, duration time.Duration, size int64, err error) result.Record {
+	return result.Record{
+		Operation: result.OperationFetch,
+		Fetches: []result.Fetch{{
+			Kind:       kind,
+			Repository: repository,
+			Timestamp:  start,
+			Duration:   duration,
+			Size:       size,
+			Err:        err,
+		}},
+	}
+}
+
+func TestNew(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := []result.Record{
+		fetchRecord(result.FetchManifest, "library/alpine", start, 100*time.Millisecond, 500, nil),
+		fetchRecord(result.FetchBlob, "library/alpine", start.Add(time.Second), time.Second, 3e6, nil),
+		fetchRecord(result.FetchBlob, "library/ubuntu", start.Add(2*time.Second), 2*time.Second, 30e6, errors.New("timeout")),
+		{Operation: result.OperationAuth, Timestamp: start, Duration: 50 * time.Millisecond, TotalCount: 1, SuccessCount: 1},
+	}
+	r, err := New(records, 0)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if got, want := r.Elapsed(), 4*time.Second; got != want {
+		t.Errorf("Elapsed() = %v, want %v", got, want)
+	}
+	if got, want := strings.Join(r.Operations, ","), "auth,manifest,blob"; got != want {
+		t.Errorf("Operations = %s, want %s", got, want)
+	}
+
+	if len(r.Repositories) != 2 {
+		t.Fatalf("got %d repositories, want 2", len(r.Repositories))
+	}
+	alpine := r.Repositories[0]
+	if alpine.Name != "library/alpine" || alpine.Count != 2 || alpine.Success != 2 || alpine.Bytes != 3e6+500 {
+		t.Errorf("repository = %+v, want the 2 fetches of library/alpine", alpine)
+	}
+
+	var sizes []string
+	for _, g := range r.Sizes {
+		sizes = append(sizes, g.Name)
+	}
+	if got, want := strings.Join(sizes, ","), "< 1MB,1MB - 10MB,10MB - 100MB"; got != want {
+		t.Errorf("Sizes = %s, want %s", got, want)
+	}
+
+	// the operations are bucketed by their end
+	if r.Interval != time.Second || len(r.Timeline) != 5 {
+		t.Fatalf("got %d buckets of %v, want 5 buckets of 1s", len(r.Timeline), r.Interval)
+	}
+	for i, want := range []int64{2, 0, 1, 0, 1} {
+		if got := r.Timeline[i].Count; got != want {
+			t.Errorf("bucket %d count = %d, want %d", i, got, want)
+		}
+	}
+	if got := r.Timeline[4].Errors; got != 1 {
+		t.Errorf("bucket 4 errors = %d, want 1", got)
+	}
+
+	// the intervals splitting the timeline into too many buckets are rejected
+	for _, interval := range []time.Duration{-time.Second, time.Nanosecond} {
+		if _, err := New(records, interval); err == nil {
+			t.Errorf("New() with interval %v succeeded, want an error", interval)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r, err := New([]result.Record{
+		fetchRecord(result.FetchBlob, "library/<alpine>", start, time.Second, 1e6, nil),
+		fetchRecord(result.FetchBlob, "library/<alpine>", start.Add(time.Second), time.Second, 1e6, nil),
+	}, 0)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf, "results.csv"); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	html := buf.String()
+	for _, want := range []string{
+		"<title>rlt report - results.csv</title>",
+		"<h2>By repository</h2>",
+		"<td>library/&lt;alpine&gt;</td>",
+		"<h2>blob latency (ms)</h2>",
+		"<h2>Throughput (MB/s)</h2>",
+		"<polyline",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML report does not contain %s", want)
+		}
+	}
+
+	// without timestamps, the charts are not available
+	buf.Reset()
+	r, err = New([]result.Record{{Operation: result.OperationPull, Name: "image.json", TotalCount: 1}}, 0)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := r.WriteHTML(&buf, "results.csv"); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	if strings.Contains(buf.String(), "<svg") {
+		t.Error("HTML report without timestamps contains charts")
+	}
+}
@@ -0,0 +1,44 @@
+package result
+
+// Error categories of the failed operations.
+const (
+	CategoryUnauthorized      = "http_401"
+	CategoryForbidden         = "http_403"
+	CategoryNotFound          = "http_404"
+	CategoryTooManyRequests   = "http_429"
+	CategoryClientError       = "http_4xx"
+	CategoryServerError       = "http_5xx"
+	CategoryTimeout           = "timeout"
+	CategoryConnectionReset   = "connection_reset"
+	CategoryConnectionRefused = "connection_refused"
+	CategoryTLS               = "tls"
+	CategoryDNS               = "dns"
+	CategoryDigestMismatch    = "digest_mismatch"
+	CategoryBodyRead          = "body_read"
+	CategoryCanceled          = "canceled"
+	// CategoryToken is the category of the fetches which could not be sent
+	// as their token could not be got, the failure of the token fetch
+	// itself being categorized by its cause.
+	CategoryToken = "token"
+	CategoryOther = "other"
+)
+
+// Categories lists the error categories in the order they are reported.
+var Categories = []string{
+	CategoryUnauthorized,
+	CategoryForbidden,
+	CategoryNotFound,
+	CategoryTooManyRequests,
+	CategoryClientError,
+	CategoryServerError,
+	CategoryTimeout,
+	CategoryConnectionReset,
+	CategoryConnectionRefused,
+	CategoryTLS,
+	CategoryDNS,
+	CategoryDigestMismatch,
+	CategoryBodyRead,
+	CategoryCanceled,
+	CategoryToken,
+	CategoryOther,
+}
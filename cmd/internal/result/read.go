@@ -0,0 +1,284 @@
+package result
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Read reads the records written in the CSV or JSONL format, detected from
+// the content. The fetches of the detailed output of pulls are read as
+// records of OperationFetch, each carrying a single fetch, since the pulls
+// they belong to are not recorded. The columns missing from the results of
+// older versions are left empty.
+func Read(r io.Reader) ([]Record, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		case '{':
+			return readJSONL(br)
+		default:
+			return readCSV(br)
+		}
+	}
+}
+
+// readJSONL reads records as JSON lines.
+func readJSONL(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	// the requests of a record may make long lines
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var probe struct {
+			Operation string `json:"operation"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if probe.Operation != FetchManifest && probe.Operation != FetchBlob && probe.Operation != FetchToken {
+			var record Record
+			if err := json.Unmarshal(data, &record); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			records = append(records, record)
+			continue
+		}
+		fetch := fetchLine{Fetch: &Fetch{}}
+		if err := json.Unmarshal(data, &fetch); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if fetch.Error != "" {
+			fetch.Err = errors.New(fetch.Error)
+		}
+		records = append(records, fetchRecord(fetch.Name, *fetch.Fetch))
+	}
+	return records, scanner.Err()
+}
+
+// readCSV reads records as CSV rows, the operation being identified by the
+// columns of the header.
+func readCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	// the columns of the results of older versions may differ
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	var parse func(f *fields) Record
+	switch {
+	case slices.Contains(header, "is_success"):
+		parse = parseAuth
+	case slices.Contains(header, "digest"):
+		parse = parseFetch
+	case slices.Contains(header, "json_file"):
+		parse = parsePull
+	default:
+		return nil, fmt.Errorf("unknown columns %q, expecting the CSV results of auth or pull", strings.Join(header, ","))
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+
+	var records []Record
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		f := &fields{index: index, row: row}
+		record := parse(f)
+		if f.err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %w", line, f.err)
+		}
+		records = append(records, record)
+	}
+}
+
+// parseAuth parses an auth row, whose timestamp is the end of the exchange.
+func parseAuth(f *fields) Record {
+	r := Record{
+		Operation:  OperationAuth,
+		Duration:   f.milliseconds("duration_milliseconds"),
+		TotalCount: 1,
+		RetryCount: f.int("retry_count"),
+	}
+	r.Timestamp = f.time("timestamp").Add(-r.Duration)
+	if f.bool("is_success") {
+		r.SuccessCount = 1
+	}
+	if f.bool("is_timeout") {
+		r.TimeoutCount = 1
+	}
+	if category := f.str("error_category"); category != "" {
+		r.Errors = make(map[string]int)
+		for _, c := range strings.Split(category, ";") {
+			r.Errors[c] = 1
+		}
+	}
+	return r
+}
+
+// parsePull parses a pull row.
+func parsePull(f *fields) Record {
+	r := Record{
+		Operation:           OperationPull,
+		Timestamp:           f.time("timestamp"),
+		Duration:            f.milliseconds("download_milliseconds"),
+		Name:                f.str("json_file"),
+		Size:                f.int("total_size"),
+		TotalCount:          int(f.int("total_count")),
+		SuccessCount:        int(f.int("success_count")),
+		TimeoutCount:        int(f.int("timeout_count")),
+		FirstAttemptSuccess: int(f.int("first_attempt_success_count")),
+		RetryCount:          f.int("retry_count"),
+		TokenRefreshes:      int(f.int("token_refresh_count")),
+	}
+	if errs := f.str("errors"); errs != "" {
+		r.Errors = make(map[string]int)
+		for _, part := range strings.Split(errs, ";") {
+			category, count, _ := strings.Cut(part, "=")
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				f.fail("errors", errs)
+				break
+			}
+			r.Errors[category] = n
+		}
+	}
+	return r
+}
+
+// parseFetch parses a fetch row of the detailed output of pulls.
+func parseFetch(f *fields) Record {
+	fetch := Fetch{
+		Kind:         f.str("operation"),
+		Repository:   f.str("repository"),
+		Digest:       f.str("digest"),
+		Timestamp:    f.time("timestamp"),
+		Duration:     f.milliseconds("duration_milliseconds"),
+		Size:         f.int("size"),
+		Status:       int(f.int("status")),
+		RedirectHost: f.str("redirect_host"),
+		Retries:      f.int("retry_count"),
+		Category:     f.str("error_category"),
+	}
+	if fetch.Category != "" {
+		// only the category of the error is recorded
+		fetch.Err = errors.New(fetch.Category)
+	}
+	return fetchRecord(f.str("json_file"), fetch)
+}
+
+// fetchRecord returns the record of OperationFetch carrying a fetch of the
+// named asset.
+func fetchRecord(name string, f Fetch) Record {
+	r := Record{
+		Operation:  OperationFetch,
+		Timestamp:  f.Timestamp,
+		Duration:   f.Duration,
+		Name:       name,
+		Size:       f.Size,
+		TotalCount: 1,
+		RetryCount: f.Retries,
+		Fetches:    []Fetch{f},
+	}
+	if f.Err == nil {
+		r.SuccessCount = 1
+	} else {
+		r.Errors = map[string]int{f.Category: 1}
+	}
+	if f.Category == CategoryTimeout {
+		r.TimeoutCount = 1
+	}
+	return r
+}
+
+// fields parses the fields of a CSV row by column name, keeping the first
+// error. The fields of missing columns are empty.
+type fields struct {
+	index map[string]int
+	row   []string
+	err   error
+}
+
+func (f *fields) str(name string) string {
+	i, ok := f.index[name]
+	if !ok || i >= len(f.row) {
+		return ""
+	}
+	return f.row[i]
+}
+
+func (f *fields) fail(name string, value string) {
+	if f.err == nil {
+		f.err = fmt.Errorf("invalid %s %q", name, value)
+	}
+}
+
+func (f *fields) int(name string) int64 {
+	value := f.str(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		f.fail(name, value)
+	}
+	return n
+}
+
+func (f *fields) bool(name string) bool {
+	value := f.str(name)
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		f.fail(name, value)
+	}
+	return b
+}
+
+func (f *fields) milliseconds(name string) time.Duration {
+	return time.Duration(f.int(name)) * time.Millisecond
+}
+
+func (f *fields) time(name string) time.Time {
+	value := f.str(name)
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		f.fail(name, value)
+	}
+	return t
+}
@@ -0,0 +1,117 @@
+package result
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	pull := Record{
+		Operation:      OperationPull,
+		Timestamp:      start,
+		Duration:       1500 * time.Millisecond,
+		Name:           "image.json",
+		Size:           2048,
+		TotalCount:     3,
+		SuccessCount:   2,
+		TimeoutCount:   1,
+		RetryCount:     4,
+		TokenRefreshes: 2,
+		Errors:         map[string]int{"timeout": 1},
+	}
+	auth := Record{
+		Operation:    OperationAuth,
+		Timestamp:    start,
+		Duration:     time.Second,
+		TotalCount:   1,
+		SuccessCount: 0,
+		Errors:       map[string]int{"http_401": 1},
+	}
+	blob := Fetch{
+		Kind:       FetchBlob,
+		Repository: "library/hello-world",
+		Digest:     "sha256:def",
+		Timestamp:  start,
+		Duration:   time.Second,
+		Status:     429,
+		Category:   "http_429",
+		Err:        errors.New("http_429"),
+	}
+	fetch := Record{
+		Operation:  OperationFetch,
+		Timestamp:  start,
+		Duration:   time.Second,
+		Name:       "image.json",
+		TotalCount: 1,
+		Errors:     map[string]int{"http_429": 1},
+		Fetches:    []Fetch{blob},
+	}
+
+	tests := []struct {
+		name      string
+		format    string
+		operation string
+		record    Record
+		want      Record
+	}{
+		{"Pull CSV", FormatCSV, OperationPull, pull, pull},
+		{"Pull JSONL", FormatJSONL, OperationPull, pull, pull},
+		{"Auth CSV", FormatCSV, OperationAuth, auth, auth},
+		{"Fetch CSV", FormatCSV, OperationFetch, Record{Operation: OperationPull, Name: "image.json", Fetches: []Fetch{blob}}, fetch},
+		{"Fetch JSONL", FormatJSONL, OperationFetch, Record{Operation: OperationPull, Name: "image.json", Fetches: []Fetch{blob}}, fetch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, tt.format, tt.operation)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			if err := w.Write(tt.record); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			got, err := Read(&buf)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if len(got) != 1 || !reflect.DeepEqual(got[0], tt.want) {
+				t.Errorf("Read() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOlderColumns(t *testing.T) {
+	// results written before the pull timestamp column was added
+	input := "json_file,total_size,download_milliseconds,total_count,success_count,timeout_count\n" +
+		"image.json,10,20,1,1,0\n"
+	got, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	want := []Record{{Operation: OperationPull, Name: "image.json", Size: 10, Duration: 20 * time.Millisecond, TotalCount: 1, SuccessCount: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, input := range []string{
+		"name,value\na,1\n",
+		"json_file,total_size\nimage.json,large\n",
+		"{\"operation\":\"pull\",\"size\":\"large\"}\n",
+	} {
+		if _, err := Read(strings.NewReader(input)); err == nil {
+			t.Errorf("Read(%q) succeeded", input)
+		}
+	}
+}
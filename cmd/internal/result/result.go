@@ -0,0 +1,394 @@
+package result
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/internal/trace"
+)
+
+// Operations of the records.
+const (
+	// OperationAuth is a token exchange.
+	OperationAuth = "auth"
+	// OperationPull is the pull of an image, i.e. its manifest and blobs.
+	OperationPull = "pull"
+	// OperationFetch selects the detailed output of pulls, where each
+	// manifest and blob fetch is written instead of the whole pull.
+	OperationFetch = "fetch"
+)
+
+// Kinds of the fetches of a pull.
+const (
+	FetchManifest = "manifest"
+	FetchBlob     = "blob"
+	// FetchToken is the request of a token of the scope of the repository.
+	FetchToken = "token"
+)
+
+// Output formats of the records.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatTable = "table"
+)
+
+// Formats lists the supported output formats.
+var Formats = []string{FormatCSV, FormatJSONL, FormatTable}
+
+// Record is the result of a test instance.
+type Record struct {
+	Operation string `json:"operation"`
+	// Timestamp is the start time of the instance.
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration_ns"`
+	// Name identifies the pulled asset.
+	Name                string `json:"name,omitempty"`
+	Size                int64  `json:"size"`
+	TotalCount          int    `json:"total_count"`
+	SuccessCount        int    `json:"success_count"`
+	TimeoutCount        int    `json:"timeout_count"`
+	FirstAttemptSuccess int    `json:"first_attempt_success_count"`
+	RetryCount          int64  `json:"retry_count"`
+	// TokenRefreshes counts the tokens of a pull refreshed as they expired or
+	// were rejected by the registry.
+	TokenRefreshes int `json:"token_refresh_count,omitempty"`
+	// Errors counts the failed operations by error category.
+	Errors map[string]int `json:"errors,omitempty"`
+	// Requests are the timings of the requests of a token exchange.
+	Requests []trace.Timing `json:"requests,omitempty"`
+	// Fetches are the token, manifest and blob fetches of a pull.
+	Fetches []Fetch `json:"-"`
+}
+
+// Fetch is the result of a manifest or blob fetch.
+type Fetch struct {
+	Kind       string `json:"operation"`
+	Repository string `json:"repository"`
+	// Digest is the digest of the content, unknown for failed manifest fetches by tag.
+	Digest    string        `json:"digest,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration_ns"`
+	Size      int64         `json:"size"`
+	// Status is the status code of the last response received.
+	Status int `json:"status,omitempty"`
+	// RedirectHost is the host the fetch was redirected to, e.g. a storage backend.
+	RedirectHost string `json:"redirect_host,omitempty"`
+	Retries      int64  `json:"retry_count"`
+	Category     string `json:"error_category,omitempty"`
+	Err          error  `json:"-"`
+	// Requests are the timings of the requests of the fetch, including the
+	// retries and redirects.
+	Requests []trace.Timing `json:"requests,omitempty"`
+}
+
+// fetchLine is the JSON line of a fetch in the detailed output.
+type fetchLine struct {
+	Name string `json:"name"`
+	*Fetch
+	Error string `json:"error,omitempty"`
+}
+
+// End returns the end time of the instance.
+func (r *Record) End() time.Time {
+	return r.Timestamp.Add(r.Duration)
+}
+
+// Writer writes records. Writers are safe for concurrent use.
+type Writer interface {
+	// Write writes a record.
+	Write(r Record) error
+	// Close flushes the records written.
+	Close() error
+}
+
+// Multi returns a writer duplicating the records to all the writers. Nil
+// writers are skipped.
+func Multi(writers ...Writer) Writer {
+	var m multiWriter
+	for _, w := range writers {
+		if w != nil {
+			m = append(m, w)
+		}
+	}
+	return m
+}
+
+// multiWriter duplicates the records to multiple writers.
+type multiWriter []Writer
+
+// Write writes the record to all the writers and returns the first error.
+func (m multiWriter) Write(r Record) error {
+	var err error
+	for _, w := range m {
+		if werr := w.Write(r); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// Close closes all the writers and returns the first error.
+func (m multiWriter) Close() error {
+	var err error
+	for _, w := range m {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// row is a row of the output, either a record or, in the detailed output of
+// pulls, a fetch of a record.
+type row struct {
+	*Record
+	fetch *Fetch
+}
+
+// rows returns the rows of a record.
+func rows(r *Record, detailed bool) []row {
+	if !detailed {
+		return []row{{Record: r}}
+	}
+	rs := make([]row, len(r.Fetches))
+	for i := range r.Fetches {
+		rs[i] = row{Record: r, fetch: &r.Fetches[i]}
+	}
+	return rs
+}
+
+// column is a column of the CSV and table formats.
+type column struct {
+	name  string
+	value func(r row) string
+}
+
+// columns are the columns of the CSV and table formats of each operation.
+var columns = map[string][]column{
+	OperationAuth: {
+		{"timestamp", func(r row) string { return r.End().Format(time.RFC3339) }},
+		{"is_success", func(r row) string { return strconv.FormatBool(r.SuccessCount > 0) }},
+		{"is_timeout", func(r row) string { return strconv.FormatBool(r.TimeoutCount > 0) }},
+		{"retry_count", func(r row) string { return strconv.FormatInt(r.RetryCount, 10) }},
+		{"error_category", func(r row) string { return strings.Join(slices.Sorted(maps.Keys(r.Errors)), ";") }},
+		{"duration_milliseconds", func(r row) string { return strconv.FormatInt(r.Duration.Milliseconds(), 10) }},
+	},
+	OperationPull: {
+		{"json_file", func(r row) string { return r.Name }},
+		{"total_size", func(r row) string { return strconv.FormatInt(r.Size, 10) }},
+		{"download_milliseconds", func(r row) string { return strconv.FormatInt(r.Duration.Milliseconds(), 10) }},
+		{"total_count", func(r row) string { return strconv.Itoa(r.TotalCount) }},
+		{"success_count", func(r row) string { return strconv.Itoa(r.SuccessCount) }},
+		{"timeout_count", func(r row) string { return strconv.Itoa(r.TimeoutCount) }},
+		{"first_attempt_success_count", func(r row) string { return strconv.Itoa(r.FirstAttemptSuccess) }},
+		{"retry_count", func(r row) string { return strconv.FormatInt(r.RetryCount, 10) }},
+		{"errors", func(r row) string { return formatErrors(r.Errors) }},
+		{"timestamp", func(r row) string { return r.Timestamp.Format(time.RFC3339Nano) }},
+		{"token_refresh_count", func(r row) string { return strconv.Itoa(r.TokenRefreshes) }},
+	},
+	OperationFetch: {
+		{"json_file", func(r row) string { return r.Name }},
+		{"operation", func(r row) string { return r.fetch.Kind }},
+		{"repository", func(r row) string { return r.fetch.Repository }},
+		{"digest", func(r row) string { return r.fetch.Digest }},
+		{"timestamp", func(r row) string { return r.fetch.Timestamp.Format(time.RFC3339Nano) }},
+		{"size", func(r row) string { return strconv.FormatInt(r.fetch.Size, 10) }},
+		{"duration_milliseconds", func(r row) string { return strconv.FormatInt(r.fetch.Duration.Milliseconds(), 10) }},
+		{"status", func(r row) string { return formatStatus(r.fetch.Status) }},
+		{"redirect_host", func(r row) string { return r.fetch.RedirectHost }},
+		{"retry_count", func(r row) string { return strconv.FormatInt(r.fetch.Retries, 10) }},
+		{"error_category", func(r row) string { return r.fetch.Category }},
+	},
+}
+
+// formatErrors formats the error counts by category, sorted by category, in
+// the format <category>=<count>;...
+func formatErrors(errs map[string]int) string {
+	categories := slices.Sorted(maps.Keys(errs))
+	parts := make([]string, len(categories))
+	for i, category := range categories {
+		parts[i] = fmt.Sprintf("%s=%d", category, errs[category])
+	}
+	return strings.Join(parts, ";")
+}
+
+// formatStatus formats a status code, empty if no response was received.
+func formatStatus(status int) string {
+	if status == 0 {
+		return ""
+	}
+	return strconv.Itoa(status)
+}
+
+// NewWriter returns a writer of the records of the operation in the format.
+// The header, if any, is written immediately. The writer of OperationFetch
+// writes the fetches of the pull records, one per row.
+func NewWriter(w io.Writer, format string, operation string) (Writer, error) {
+	cols, ok := columns[operation]
+	if !ok {
+		return nil, fmt.Errorf("unknown operation %q", operation)
+	}
+	detailed := operation == OperationFetch
+	var rw recordWriter
+	switch format {
+	case FormatCSV:
+		rw = &csvWriter{w: csv.NewWriter(w), columns: cols, detailed: detailed}
+	case FormatJSONL:
+		rw = &jsonlWriter{enc: json.NewEncoder(w), detailed: detailed}
+	case FormatTable:
+		rw = &tableWriter{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0), columns: cols, detailed: detailed}
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expecting csv, jsonl or table", format)
+	}
+	if err := rw.header(); err != nil {
+		return nil, err
+	}
+	return &syncWriter{w: rw}, nil
+}
+
+// recordWriter writes records in a format.
+type recordWriter interface {
+	header() error
+	write(r *Record) error
+	flush() error
+}
+
+// syncWriter serializes the writes of a recordWriter.
+type syncWriter struct {
+	mu sync.Mutex
+	w  recordWriter
+}
+
+// Write writes a record.
+func (s *syncWriter) Write(r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.write(&r)
+}
+
+// Close flushes the records written.
+func (s *syncWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.flush()
+}
+
+// csvWriter writes records as CSV rows. Rows are flushed as they are written
+// so that the results of long runs can be followed.
+type csvWriter struct {
+	w        *csv.Writer
+	columns  []column
+	detailed bool
+}
+
+func (c *csvWriter) header() error {
+	row := make([]string, len(c.columns))
+	for i, col := range c.columns {
+		row[i] = col.name
+	}
+	return c.writeRow(row)
+}
+
+func (c *csvWriter) write(r *Record) error {
+	for _, rw := range rows(r, c.detailed) {
+		row := make([]string, len(c.columns))
+		for i, col := range c.columns {
+			row[i] = col.value(rw)
+		}
+		if err := c.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *csvWriter) writeRow(row []string) error {
+	if err := c.w.Write(row); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlWriter writes records as JSON lines with all their fields.
+type jsonlWriter struct {
+	enc      *json.Encoder
+	detailed bool
+}
+
+func (j *jsonlWriter) header() error {
+	return nil
+}
+
+func (j *jsonlWriter) write(r *Record) error {
+	if !j.detailed {
+		return j.enc.Encode(r)
+	}
+	for _, rw := range rows(r, true) {
+		line := fetchLine{Name: r.Name, Fetch: rw.fetch}
+		if rw.fetch.Err != nil {
+			line.Error = rw.fetch.Err.Error()
+		}
+		if err := j.enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *jsonlWriter) flush() error {
+	return nil
+}
+
+// tableWriter writes records as an aligned table. The columns are aligned
+// on all the rows, so the table is only written when the writer is closed.
+type tableWriter struct {
+	w        *tabwriter.Writer
+	columns  []column
+	detailed bool
+}
+
+func (t *tableWriter) header() error {
+	for i, col := range t.columns {
+		if i > 0 {
+			fmt.Fprint(t.w, "\t")
+		}
+		fmt.Fprint(t.w, col.name)
+	}
+	_, err := fmt.Fprintln(t.w)
+	return err
+}
+
+func (t *tableWriter) write(r *Record) error {
+	for _, rw := range rows(r, t.detailed) {
+		for i, col := range t.columns {
+			if i > 0 {
+				fmt.Fprint(t.w, "\t")
+			}
+			fmt.Fprint(t.w, col.value(rw))
+		}
+		if _, err := fmt.Fprintln(t.w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tableWriter) flush() error {
+	return t.w.Flush()
+}
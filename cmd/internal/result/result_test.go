@@ -0,0 +1,158 @@
+package result
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWriter(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	pull := Record{
+		Operation:           OperationPull,
+		Timestamp:           start,
+		Duration:            1500 * time.Millisecond,
+		Name:                "assets/images/hello-world:latest.json",
+		Size:                2048,
+		TotalCount:          3,
+		SuccessCount:        2,
+		TimeoutCount:        1,
+		FirstAttemptSuccess: 1,
+		RetryCount:          4,
+		TokenRefreshes:      1,
+		Errors:              map[string]int{"timeout": 1, "http_429": 2},
+	}
+	auth := Record{
+		Operation:    OperationAuth,
+		Timestamp:    start,
+		Duration:     time.Second,
+		TotalCount:   1,
+		SuccessCount: 1,
+	}
+
+	failedAuth := Record{
+		Operation:    OperationAuth,
+		Timestamp:    start,
+		Duration:     time.Second,
+		TotalCount:   1,
+		TimeoutCount: 1,
+		RetryCount:   1,
+		Errors:       map[string]int{"timeout": 1},
+	}
+	pullWithFetches := Record{
+		Operation: OperationPull,
+		Timestamp: start,
+		Name:      "image.json",
+		Fetches: []Fetch{
+			{Kind: FetchManifest, Repository: "library/hello-world", Digest: "sha256:abc", Timestamp: start, Duration: 20 * time.Millisecond, Size: 512, Status: 200},
+			{Kind: FetchBlob, Repository: "library/hello-world", Digest: "sha256:def", Timestamp: start.Add(20 * time.Millisecond), Duration: time.Second, RedirectHost: "storage.example.com", Retries: 2, Category: "timeout"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		format    string
+		operation string
+		record    Record
+		want      string
+	}{
+		{
+			name:      "Pull CSV",
+			format:    FormatCSV,
+			operation: OperationPull,
+			record:    pull,
+			want: "json_file,total_size,download_milliseconds,total_count,success_count,timeout_count,first_attempt_success_count,retry_count,errors,timestamp,token_refresh_count\n" +
+				"assets/images/hello-world:latest.json,2048,1500,3,2,1,1,4,http_429=2;timeout=1,2024-01-02T03:04:05Z,1\n",
+		},
+		{
+			name:      "Auth CSV",
+			format:    FormatCSV,
+			operation: OperationAuth,
+			record:    auth,
+			want:      "timestamp,is_success,is_timeout,retry_count,error_category,duration_milliseconds\n2024-01-02T03:04:06Z,true,false,0,,1000\n",
+		},
+		{
+			name:      "Fetch CSV",
+			format:    FormatCSV,
+			operation: OperationFetch,
+			record:    pullWithFetches,
+			want: "json_file,operation,repository,digest,timestamp,size,duration_milliseconds,status,redirect_host,retry_count,error_category\n" +
+				"image.json,manifest,library/hello-world,sha256:abc,2024-01-02T03:04:05Z,512,20,200,,0,\n" +
+				"image.json,blob,library/hello-world,sha256:def,2024-01-02T03:04:05.02Z,0,1000,,storage.example.com,2,timeout\n",
+		},
+		{
+			name:      "Failed auth table",
+			format:    FormatTable,
+			operation: OperationAuth,
+			record:    failedAuth,
+			want: "timestamp             is_success  is_timeout  retry_count  error_category  duration_milliseconds\n" +
+				"2024-01-02T03:04:06Z  false       true        1            timeout         1000\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, tt.format, tt.operation)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			if err := w.Write(tt.record); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWriterJSONL(t *testing.T) {
+	record := Record{
+		Operation:  OperationPull,
+		Timestamp:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:   time.Second,
+		Name:       "image.json",
+		Size:       10,
+		TotalCount: 2,
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatJSONL, OperationPull)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for range 2 {
+		if err := w.Write(record); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var got Record
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("failed to parse line: %v", err)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Errorf("decoded record = %+v, want %+v", got, record)
+	}
+}
+
+func TestNewWriterErrors(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, "xml", OperationPull); err == nil {
+		t.Error("NewWriter() with unknown format succeeded")
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, FormatCSV, "push"); err == nil {
+		t.Error("NewWriter() with unknown operation succeeded")
+	}
+}
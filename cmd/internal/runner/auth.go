@@ -1,26 +1,87 @@
 package runner
 
-import "github.com/billy-playground/registry-load-tester/internal/auth"
+import (
+	"context"
+	"time"
 
-// AuthRunner can be used to start a new test instance to download blobs and manifests.
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/internal/auth"
+	"github.com/billy-playground/registry-load-tester/internal/retry"
+	"github.com/billy-playground/registry-load-tester/internal/trace"
+)
+
+// AuthOptions represents the options of the test instances started by an AuthRunner.
+type AuthOptions struct {
+	Timeouts Timeouts
+	// Results receives the record of each instance.
+	Results result.Writer
+}
+
+// AuthRunner can be used to start a new test instance to exchange tokens.
 type AuthRunner struct {
-	realm        string
-	service      string
-	registry     string
-	refreshToken string
+	registry  string
+	challenge auth.Challenge
+	request   auth.TokenRequest
+	opts      AuthOptions
 }
 
-func NewAuthRunner(realm string, service string, registry string, refreshToken string) *AuthRunner {
+// NewAuthRunner returns a runner requesting tokens from the token service of
+// the challenge, or authenticating with basic auth if the registry only
+// supports basic authentication.
+func NewAuthRunner(registry string, challenge auth.Challenge, request auth.TokenRequest, opts AuthOptions) *AuthRunner {
 	return &AuthRunner{
-		realm:        realm,
-		service:      service,
-		registry:     registry,
-		refreshToken: refreshToken,
+		registry:  registry,
+		challenge: challenge,
+		request:   request,
+		opts:      opts,
 	}
 }
 
-// StartNew starts a new test instance to download blobs and manifests.
-func (r *AuthRunner) StartNew() error {
-	_, err := auth.ExchangeToken(r.realm, r.service, r.refreshToken)
+// StartNew starts a new test instance to exchange a token scoped to pull from
+// the repository and writes its record. Errors caused by the timeouts are
+// recognized by IsTimeout.
+func (r *AuthRunner) StartNew(ctx context.Context, repository string) error {
+	record := result.Record{
+		Operation:  result.OperationAuth,
+		Timestamp:  time.Now(),
+		TotalCount: 1,
+	}
+	err := r.exchange(ctx, repository, &record)
+	record.Duration = time.Since(record.Timestamp)
+	switch {
+	case err == nil:
+		record.SuccessCount = 1
+		if record.RetryCount == 0 {
+			record.FirstAttemptSuccess = 1
+		}
+	case IsTimeout(err):
+		record.TimeoutCount = 1
+	}
+	if err != nil {
+		record.Errors = map[string]int{Category(err, lastStatus(record.Requests)): 1}
+	}
+	writeRecord(r.opts.Results, record)
 	return err
 }
+
+// exchange exchanges a token, or authenticates with basic auth, within the
+// timeouts, recording the retries and the timings of the requests.
+func (r *AuthRunner) exchange(ctx context.Context, repository string, record *result.Record) error {
+	ctx, cancel := withTimeout(ctx, r.opts.Timeouts.Instance)
+	defer cancel()
+	ctx, cancelRequest := withTimeout(ctx, r.opts.Timeouts.Request)
+	defer cancelRequest()
+	ctx, counter := retry.WithCounter(ctx)
+	ctx, recorder := trace.WithRecorder(ctx)
+	var err error
+	if r.challenge.Scheme == "basic" {
+		err = auth.Ping(ctx, r.registry, auth.BasicAuthorization(r.request.Credential))
+	} else {
+		request := r.request
+		request.Scopes = []string{auth.RepositoryScope(repository)}
+		_, err = auth.FetchToken(ctx, request)
+	}
+	record.RetryCount = counter.Retries()
+	record.Requests = recorder.Timings()
+	return timeoutError(ctx, err)
+}
@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/internal/auth"
+)
+
+func TestAuthStartNew(t *testing.T) {
+	var scopes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes = append(scopes, r.URL.Query().Get("scope"))
+		json.NewEncoder(w).Encode(map[string]string{"token": "t"})
+	}))
+	defer server.Close()
+
+	var records []result.Record
+	challenge := auth.Challenge{Scheme: "bearer", Realm: server.URL}
+	r := NewAuthRunner("registry.example.com", challenge, auth.TokenRequest{Flow: auth.FlowBearer, Realm: server.URL}, AuthOptions{
+		Results: recordsFunc(func(record result.Record) {
+			records = append(records, record)
+		}),
+	})
+	for _, repository := range []string{"library/a", "library/b"} {
+		if err := r.StartNew(context.Background(), repository); err != nil {
+			t.Fatalf("StartNew(%q) error = %v", repository, err)
+		}
+	}
+	want := []string{"repository:library/a:pull", "repository:library/b:pull"}
+	if len(scopes) != len(want) || scopes[0] != want[0] || scopes[1] != want[1] {
+		t.Errorf("requested scopes = %v, want %v", scopes, want)
+	}
+	if len(records) != 2 || records[0].SuccessCount != 1 || records[1].SuccessCount != 1 {
+		t.Errorf("StartNew() records = %+v, want 2 successful records", records)
+	}
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/internal/auth"
+)
+
+// errReadBody marks the errors reading a response body.
+var errReadBody = errors.New("failed to read response")
+
+// Category returns the category of an operation error, empty on success.
+// status is the status code of the last response received, if any, used when
+// the error does not carry it.
+func Category(err error, status int) string {
+	if err == nil {
+		return ""
+	}
+	if IsTimeout(err) {
+		return result.CategoryTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return result.CategoryCanceled
+	}
+	if code := statusCode(err); code != 0 {
+		status = code
+	}
+	switch {
+	case status == 401:
+		return result.CategoryUnauthorized
+	case status == 403:
+		return result.CategoryForbidden
+	case status == 404:
+		return result.CategoryNotFound
+	case status == 429:
+		return result.CategoryTooManyRequests
+	case status >= 500:
+		return result.CategoryServerError
+	case status >= 400:
+		return result.CategoryClientError
+	}
+
+	var dnsErr *net.DNSError
+	var recordHeaderErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateErr x509.CertificateInvalidError
+	switch {
+	case errors.Is(err, content.ErrMismatchedDigest), errors.Is(err, content.ErrTrailingData):
+		return result.CategoryDigestMismatch
+	case errors.As(err, &dnsErr):
+		return result.CategoryDNS
+	case errors.As(err, &recordHeaderErr), errors.As(err, &alertErr), errors.As(err, &verificationErr),
+		errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr), errors.As(err, &certificateErr):
+		return result.CategoryTLS
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return result.CategoryConnectionReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return result.CategoryConnectionRefused
+	case errors.Is(err, errReadBody), errors.Is(err, io.ErrUnexpectedEOF):
+		return result.CategoryBodyRead
+	default:
+		return result.CategoryOther
+	}
+}
+
+// statusCode returns the status code carried by an error, 0 if none.
+func statusCode(err error) int {
+	var errResp *errcode.ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp.StatusCode
+	}
+	var statusErr *auth.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
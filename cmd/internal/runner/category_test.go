@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/internal/auth"
+)
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		want   string
+	}{
+		{name: "Success", status: 200},
+		{name: "Deadline", err: context.DeadlineExceeded, want: result.CategoryTimeout},
+		{name: "Stall", err: fmt.Errorf("%w: %w", errReadBody, ErrStalled), want: result.CategoryTimeout},
+		{name: "Canceled", err: context.Canceled, want: result.CategoryCanceled},
+		{name: "Registry error response", err: &errcode.ErrorResponse{StatusCode: 429}, want: result.CategoryTooManyRequests},
+		{name: "Token service error", err: fmt.Errorf("token: %w", &auth.StatusError{StatusCode: 503}), want: result.CategoryServerError},
+		{name: "Not found", err: errdef.ErrNotFound, status: 404, want: result.CategoryNotFound},
+		{name: "Unauthorized", err: &errcode.ErrorResponse{StatusCode: 401}, want: result.CategoryUnauthorized},
+		{name: "Other client error", err: &errcode.ErrorResponse{StatusCode: 400}, want: result.CategoryClientError},
+		{name: "DNS", err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host"}}, want: result.CategoryDNS},
+		{name: "TLS", err: fmt.Errorf("get: %w", x509.UnknownAuthorityError{}), want: result.CategoryTLS},
+		{name: "Connection reset", err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, want: result.CategoryConnectionReset},
+		{name: "Connection refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: result.CategoryConnectionRefused},
+		{name: "Digest mismatch", err: fmt.Errorf("verify: %w", content.ErrMismatchedDigest), status: 200, want: result.CategoryDigestMismatch},
+		{name: "Truncated body", err: fmt.Errorf("%w: %w", errReadBody, io.ErrUnexpectedEOF), status: 200, want: result.CategoryBodyRead},
+		{name: "Other", err: errors.New("boom"), want: result.CategoryOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Category(tt.err, tt.status); got != tt.want {
+				t.Errorf("Category(%v, %d) = %q, want %q", tt.err, tt.status, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,8 @@
 package runner
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,131 +11,368 @@ import (
 	"sync/atomic"
 	"time"
 
-	"context"
-
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
 
-	"github.com/billy-playground/registry-load-tester/cmd/internal/image"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	tokenauth "github.com/billy-playground/registry-load-tester/internal/auth"
+	"github.com/billy-playground/registry-load-tester/internal/retry"
+	"github.com/billy-playground/registry-load-tester/internal/trace"
 )
 
+// PullOptions represents the options of the test instances started by a PullRunner.
+type PullOptions struct {
+	// MaxFetches bounds the number of concurrent manifest and blob fetches of
+	// an instance. 0 means no limit.
+	MaxFetches int
+	// VerifyDigest verifies the size and the digest of the fetched content.
+	VerifyDigest bool
+	Timeouts     Timeouts
+	// TokenRequest, if set, requests a token of the scope of the repository
+	// of each instance before its fetches, recorded as a fetch of FetchToken.
+	TokenRequest *tokenauth.TokenRequest
+	// TokenCache, if set, shares the tokens of the scopes between the
+	// instances, only the fetches of the tokens which are not cached yet
+	// being recorded.
+	TokenCache *tokenauth.TokenCache
+	// Results receives the record of each instance.
+	Results result.Writer
+}
+
 // PullRunner can be used to start a new test instance to download blobs and manifests.
 type PullRunner struct {
-	accessToken string
-	registry    string
+	authorization string
+	registry      string
+	opts          PullOptions
 }
 
-// It takes a JSON file as input and downloads the blobs and manifests specified in the file.
-func NewPullRunner(accessToken string, registry string) *PullRunner {
+// NewPullRunner creates a PullRunner which downloads the blobs and manifests of the
+// given assets from the registry.
+func NewPullRunner(authorization string, registry string, opts PullOptions) *PullRunner {
 	return &PullRunner{
-		accessToken: accessToken,
-		registry:    registry,
+		authorization: authorization,
+		registry:      registry,
+		opts:          opts,
 	}
 }
 
 // StartNew starts a new test instance to download blobs and manifests.
-func (r *PullRunner) StartNew(fileName string) error {
-	// Parse JSON file
-	data, err := parseJSON(fileName)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing JSON: %v\n", err)
-		os.Exit(1)
-	}
-
+// In-flight fetches are aborted once ctx is done, and the partial results of
+// the instance are still reported.
+func (r *PullRunner) StartNew(ctx context.Context, data asset.Asset) error {
 	// Record start time
 	startTime := time.Now()
 
 	// Set up repository client
-	ctx := context.Background()
 	repo, err := remote.NewRepository(data.Manifest)
-	repo.Reference.Registry = r.registry
 	if err != nil {
+		// the instance still completes, for the active instances to be
+		// accounted for
+		writeRecord(r.opts.Results, result.Record{
+			Operation:  result.OperationPull,
+			Timestamp:  startTime,
+			Duration:   time.Since(startTime),
+			Name:       data.Name,
+			TotalCount: 1 + len(data.Blobs),
+			Errors:     map[string]int{result.CategoryOther: 1},
+		})
 		return fmt.Errorf("failed to create repository: %w", err)
 	}
-	client := &auth.Client{
-		Cache:  auth.NewCache(),
-		Client: http.DefaultClient,
-	}
-	if r.accessToken != "" {
-		client.Header = http.Header{
-			"Authorization": []string{fmt.Sprintf("Bearer %s", r.accessToken)},
-		}
-	}
-	repo.Client = client
+	repo.Reference.Registry = r.registry
+
+	// Bound the whole instance
+	instanceCtx, cancel := withTimeout(ctx, r.opts.Timeouts.Instance)
+	defer cancel()
 
 	// Download manifest and blobs concurrently
 	var wg sync.WaitGroup
-	var totalCount = 1 + len(data.Blobs)
+	var totalCount atomic.Int32
 	var successCount atomic.Int32
+	var timeoutCount atomic.Int32
+	var retryCount atomic.Int64
+	var firstAttemptCount atomic.Int32
 	var downloadedSize atomic.Int64
+	var fetchesMu sync.Mutex
+	var fetches []result.Fetch
 	var ref = repo.Reference
+	totalCount.Store(int32(1 + len(data.Blobs)))
+
+	// Bound the concurrent fetches of the instance
+	var fetchSlots chan struct{}
+	if r.opts.MaxFetches > 0 {
+		fetchSlots = make(chan struct{}, r.opts.MaxFetches)
+	}
+	acquire := func() {
+		if fetchSlots != nil {
+			fetchSlots <- struct{}{}
+		}
+	}
+	release := func() {
+		if fetchSlots != nil {
+			<-fetchSlots
+		}
+	}
+	record := func(f result.Fetch) {
+		fetchesMu.Lock()
+		fetches = append(fetches, f)
+		fetchesMu.Unlock()
+		downloadedSize.Add(f.Size)
+		retryCount.Add(f.Retries)
+		switch {
+		case f.Err == nil:
+			successCount.Add(1)
+			if f.Retries == 0 {
+				firstAttemptCount.Add(1)
+			}
+		case IsTimeout(f.Err):
+			timeoutCount.Add(1)
+			fallthrough
+		default:
+			reportError(ctx, "Error downloading "+f.Kind+": %v\n", f.Err)
+		}
+	}
+
+	// Request the token of the repository before the fetches
+	var tokens *tokenSource
+	recordToken := func(f result.Fetch) {
+		totalCount.Add(1)
+		record(f)
+	}
+	if r.opts.TokenRequest != nil {
+		tokens = r.newTokenSource(ref.Repository)
+		f, _, recorded := tokens.get(instanceCtx)
+		if recorded {
+			recordToken(f)
+		}
+		if f.Err != nil {
+			// the manifest and blobs cannot be fetched without a token
+			record(tokenFailed(result.Fetch{Kind: result.FetchManifest, Repository: ref.Repository}, f))
+			for _, blob := range data.Blobs {
+				digest := blob
+				if ref, err := registry.ParseReference(blob); err == nil {
+					digest = ref.Reference
+				}
+				record(tokenFailed(result.Fetch{Kind: result.FetchBlob, Repository: ref.Repository, Digest: digest}, f))
+			}
+			data.Manifest, data.Blobs = "", nil
+		}
+	} else {
+		client := &auth.Client{
+			Cache:  auth.NewCache(),
+			Client: http.DefaultClient,
+		}
+		if r.authorization != "" {
+			client.Header = http.Header{
+				"Authorization": []string{r.authorization},
+			}
+		}
+		repo.Client = client
+	}
 
 	if data.Manifest != "" {
+		acquire()
 		wg.Add(1)
-		go func(store registry.ManifestStore, manifest string) {
+		go func(manifest string) {
 			defer wg.Done()
+			defer release()
 			// Fetch the manifest
-			_, rc, err := store.FetchReference(ctx, manifest)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error downloading manifest: %v\n", err)
-				return
-			}
-			defer rc.Close()
-			size, err := io.Copy(io.Discard, rc)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error reading manifest response: %v\n", err)
-				return
+			f := result.Fetch{Kind: result.FetchManifest, Repository: ref.Repository}
+			if d, err := ref.Digest(); err == nil {
+				f.Digest = d.String()
 			}
-			downloadedSize.Add(size)
-			successCount.Add(1)
-		}(repo.Manifests(), ref.Reference)
+			record(r.fetchAuthorized(instanceCtx, repo, tokens, recordToken, f, func(ctx context.Context, repo *remote.Repository) (ocispec.Descriptor, io.ReadCloser, error) {
+				return repo.Manifests().FetchReference(ctx, manifest)
+			}))
+		}(ref.Reference)
 	}
 
 	for _, blob := range data.Blobs {
+		acquire()
 		wg.Add(1)
-		go func(store registry.BlobStore, blob string) {
+		go func(blob string) {
+			defer wg.Done()
+			defer release()
 			ref, err := registry.ParseReference(blob)
 			if err != nil {
+				record(result.Fetch{
+					Kind:       result.FetchBlob,
+					Repository: repo.Reference.Repository,
+					Digest:     blob,
+					Timestamp:  time.Now(),
+					Err:        fmt.Errorf("invalid blob reference: %w", err),
+					Category:   result.CategoryOther,
+				})
 				return
 			}
-			defer wg.Done()
 			// Fetch the blob
-			_, rc, err := store.FetchReference(ctx, ref.Reference)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error downloading blob: %v\n", err)
-				return
-			}
-			defer rc.Close()
-			size, err := io.Copy(io.Discard, rc)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error reading blob response: %v\n", err)
-				return
-			}
-			downloadedSize.Add(size)
-			successCount.Add(1)
-		}(repo.Blobs(), blob)
+			f := result.Fetch{Kind: result.FetchBlob, Repository: repo.Reference.Repository, Digest: ref.Reference}
+			record(r.fetchAuthorized(instanceCtx, repo, tokens, recordToken, f, func(ctx context.Context, repo *remote.Repository) (ocispec.Descriptor, io.ReadCloser, error) {
+				return repo.Blobs().FetchReference(ctx, ref.Reference)
+			}))
+		}(blob)
 	}
 
 	wg.Wait()
 
-	// Record end time and calculate elapsed time
-	endTime := time.Now()
-	downloadMilliseconds := endTime.Sub(startTime).Milliseconds()
-
 	// Output results
-	fmt.Printf("%s,%d,%d,%d,%d\n", fileName, downloadedSize.Load(), downloadMilliseconds, totalCount, successCount.Load())
-	return nil
+	var errs map[string]int
+	for _, f := range fetches {
+		if f.Category != "" {
+			if errs == nil {
+				errs = make(map[string]int)
+			}
+			errs[f.Category]++
+		}
+	}
+	writeRecord(r.opts.Results, result.Record{
+		Operation:           result.OperationPull,
+		Timestamp:           startTime,
+		Duration:            time.Since(startTime),
+		Name:                data.Name,
+		Size:                downloadedSize.Load(),
+		TotalCount:          int(totalCount.Load()),
+		SuccessCount:        int(successCount.Load()),
+		TimeoutCount:        int(timeoutCount.Load()),
+		FirstAttemptSuccess: int(firstAttemptCount.Load()),
+		RetryCount:          retryCount.Load(),
+		TokenRefreshes:      tokens.refreshCount(),
+		Errors:              errs,
+		Fetches:             fetches,
+	})
+	return ctx.Err()
 }
 
-func parseJSON(filePath string) (*image.Data, error) {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, err
+// fetchAuthorized fetches the content opened by open from the repository,
+// authorized with the token of the instance if tokens is set. The token is
+// refreshed before it expires, and once the registry rejects it, the content
+// being fetched again. The fetches of the tokens are recorded by recordToken.
+func (r *PullRunner) fetchAuthorized(ctx context.Context, repo *remote.Repository, tokens *tokenSource, recordToken func(result.Fetch), f result.Fetch, open func(ctx context.Context, repo *remote.Repository) (ocispec.Descriptor, io.ReadCloser, error)) result.Fetch {
+	if tokens == nil {
+		return r.fetch(ctx, f, func(ctx context.Context) (ocispec.Descriptor, io.ReadCloser, error) {
+			return open(ctx, repo)
+		})
+	}
+	var rejected *result.Fetch
+	for {
+		tf, accessToken, recorded := tokens.get(ctx)
+		if recorded {
+			recordToken(tf)
+		}
+		if tf.Err != nil {
+			return tokenFailed(f, tf)
+		}
+		authorized := &remote.Repository{
+			Reference: repo.Reference,
+			Client:    &authorizedClient{authorization: "Bearer " + accessToken},
+		}
+		got := r.fetch(ctx, f, func(ctx context.Context) (ocispec.Descriptor, io.ReadCloser, error) {
+			return open(ctx, authorized)
+		})
+		if rejected != nil {
+			// the fetch is retried with the refreshed token
+			got.Timestamp = rejected.Timestamp
+			got.Duration = time.Since(rejected.Timestamp)
+			got.Retries += rejected.Retries + 1
+			got.Requests = append(rejected.Requests, got.Requests...)
+			return got
+		}
+		if got.Status != http.StatusUnauthorized {
+			return got
+		}
+		tokens.invalidate(accessToken)
+		rejected = &got
 	}
-	var blobData image.Data
-	if err := json.Unmarshal(data, &blobData); err != nil {
-		return nil, err
+}
+
+// fetch fetches the content opened by open and discards it, applying the
+// request and stall timeouts. It completes f with the result of the fetch,
+// including the bytes read, the retries and the timings of the requests sent.
+func (r *PullRunner) fetch(ctx context.Context, f result.Fetch, open func(ctx context.Context) (ocispec.Descriptor, io.ReadCloser, error)) result.Fetch {
+	f.Timestamp = time.Now()
+	ctx, cancel := withTimeout(ctx, r.opts.Timeouts.Request)
+	defer cancel()
+	ctx, detector, stop := withStallDetector(ctx, r.opts.Timeouts.Stall)
+	defer stop()
+	ctx, counter := retry.WithCounter(ctx)
+	ctx, recorder := trace.WithRecorder(ctx)
+
+	func() {
+		desc, rc, err := open(ctx)
+		if err != nil {
+			f.Err = timeoutError(ctx, err)
+			return
+		}
+		defer rc.Close()
+		f.Digest = desc.Digest.String()
+		var reader io.Reader = detector.reader(rc)
+		var verifier *content.VerifyReader
+		if r.opts.VerifyDigest && desc.Size >= 0 {
+			verifier = content.NewVerifyReader(reader, desc)
+			reader = verifier
+		}
+		if f.Size, err = io.Copy(io.Discard, reader); err != nil {
+			f.Err = timeoutError(ctx, fmt.Errorf("%w: %w", errReadBody, err))
+			return
+		}
+		if verifier != nil {
+			if err := verifier.Verify(); err != nil {
+				f.Err = fmt.Errorf("failed to verify %s: %w", desc.Digest, err)
+			}
+		}
+	}()
+
+	f.Duration = time.Since(f.Timestamp)
+	f.Retries = counter.Retries()
+	f.Requests = recorder.Timings()
+	f.Status, f.RedirectHost = responseOf(f.Requests)
+	f.Category = Category(f.Err, lastStatus(f.Requests))
+	return f
+}
+
+// responseOf returns the status code of the last response received and the
+// host the requests were redirected to, if any.
+func responseOf(timings []trace.Timing) (int, string) {
+	var status int
+	var redirectHost string
+	for _, t := range timings {
+		if t.Status != 0 {
+			status = t.Status
+		}
+		if t.Host != timings[0].Host {
+			redirectHost = t.Host
+		}
+	}
+	return status, redirectHost
+}
+
+// lastStatus returns the status code of the last request, 0 if it received no
+// response.
+func lastStatus(timings []trace.Timing) int {
+	if len(timings) == 0 {
+		return 0
+	}
+	return timings[len(timings)-1].Status
+}
+
+// writeRecord writes the record of an instance, if results are collected.
+func writeRecord(results result.Writer, record result.Record) {
+	if results == nil {
+		return
+	}
+	if err := results.Write(record); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing result: %v\n", err)
+	}
+}
+
+// reportError prints a fetch error, unless the fetch was aborted by the
+// cancellation of the run.
+func reportError(ctx context.Context, format string, err error) {
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return
 	}
-	return &blobData, nil
+	fmt.Fprintf(os.Stderr, format, err)
 }
@@ -0,0 +1,348 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry/remote"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/image"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/internal/auth"
+	"github.com/billy-playground/registry-load-tester/internal/trace"
+)
+
+func TestResponseOf(t *testing.T) {
+	tests := []struct {
+		name             string
+		timings          []trace.Timing
+		wantStatus       int
+		wantRedirectHost string
+	}{
+		{name: "No request"},
+		{
+			name:       "Direct response",
+			timings:    []trace.Timing{{Host: "registry.example.com", Status: 200}},
+			wantStatus: 200,
+		},
+		{
+			name: "Redirected to storage",
+			timings: []trace.Timing{
+				{Host: "registry.example.com", Status: 307},
+				{Host: "storage.example.com", Status: 200},
+			},
+			wantStatus:       200,
+			wantRedirectHost: "storage.example.com",
+		},
+		{
+			name: "Connection failure after redirect",
+			timings: []trace.Timing{
+				{Host: "registry.example.com", Status: 307},
+				{Host: "storage.example.com"},
+			},
+			wantStatus:       307,
+			wantRedirectHost: "storage.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, redirectHost := responseOf(tt.timings)
+			if status != tt.wantStatus || redirectHost != tt.wantRedirectHost {
+				t.Errorf("responseOf() = %d, %q, want %d, %q", status, redirectHost, tt.wantStatus, tt.wantRedirectHost)
+			}
+		})
+	}
+}
+
+func TestFetchVerifyDigest(t *testing.T) {
+	blob := []byte("hello world")
+	open := func(desc ocispec.Descriptor) func(ctx context.Context) (ocispec.Descriptor, io.ReadCloser, error) {
+		return func(ctx context.Context) (ocispec.Descriptor, io.ReadCloser, error) {
+			return desc, io.NopCloser(bytes.NewReader(blob)), nil
+		}
+	}
+	tests := []struct {
+		name         string
+		desc         ocispec.Descriptor
+		wantCategory string
+	}{
+		{name: "Valid content", desc: content.NewDescriptorFromBytes("", blob)},
+		{name: "Mismatched digest", desc: content.NewDescriptorFromBytes("", []byte("hello there")), wantCategory: result.CategoryDigestMismatch},
+		{name: "Trailing data", desc: content.NewDescriptorFromBytes("", blob[:5]), wantCategory: result.CategoryDigestMismatch},
+		{name: "Truncated content", desc: ocispec.Descriptor{Digest: digest.FromBytes(blob), Size: 20}, wantCategory: result.CategoryBodyRead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewPullRunner("", "", PullOptions{VerifyDigest: true})
+			f := r.fetch(context.Background(), result.Fetch{Kind: result.FetchBlob}, open(tt.desc))
+			if f.Category != tt.wantCategory {
+				t.Errorf("fetch() category = %q, want %q, error = %v", f.Category, tt.wantCategory, f.Err)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		json.NewEncoder(w).Encode(map[string]string{"token": r.URL.Query().Get("scope")})
+	}))
+	defer server.Close()
+	request := &auth.TokenRequest{Flow: auth.FlowBearer, Realm: server.URL}
+
+	tests := []struct {
+		name         string
+		cache        *auth.TokenCache
+		wantRequests int32
+	}{
+		{name: "Token per instance", wantRequests: 2},
+		{name: "Shared tokens", cache: auth.NewTokenCache(), wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			r := NewPullRunner("", "", PullOptions{TokenRequest: request, TokenCache: tt.cache})
+			var recorded int32
+			// the tokens of two instances
+			for range 2 {
+				f, token, ok := r.newTokenSource("library/alpine").get(context.Background())
+				if f.Err != nil {
+					t.Fatalf("get() error = %v", f.Err)
+				}
+				if want := "repository:library/alpine:pull"; token != want {
+					t.Errorf("get() = %q, want %q", token, want)
+				}
+				if ok {
+					recorded++
+					if f.Kind != result.FetchToken || f.Repository != "library/alpine" {
+						t.Errorf("get() fetch = %+v, want a token fetch of library/alpine", f)
+					}
+				}
+			}
+			if got := requests.Load(); got != tt.wantRequests || recorded != tt.wantRequests {
+				t.Errorf("got %d requests and %d recorded fetches, want %d", got, recorded, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	var grants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		grants = append(grants, r.PostForm.Get("grant_type")+" "+r.PostForm.Get("password")+r.PostForm.Get("refresh_token"))
+		response := map[string]string{"access_token": fmt.Sprintf("t%d", len(grants))}
+		if len(grants) == 1 {
+			// the refresh token is kept if no new one is issued
+			response["refresh_token"] = "issued"
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	request := &auth.TokenRequest{Flow: auth.FlowPassword, Realm: server.URL, Credential: auth.Credential{Username: "user", Password: "pass"}}
+	r := NewPullRunner("", "", PullOptions{TokenRequest: request, TokenCache: auth.NewTokenCache()})
+	tokens := r.newTokenSource("library/alpine")
+	for range 3 {
+		f, token, _ := tokens.get(context.Background())
+		if f.Err != nil {
+			t.Fatalf("get() error = %v", f.Err)
+		}
+		tokens.invalidate(token)
+	}
+	want := []string{"password pass", "refresh_token issued", "refresh_token issued"}
+	if !reflect.DeepEqual(grants, want) {
+		t.Errorf("grants = %q, want %q", grants, want)
+	}
+	if got := tokens.refreshCount(); got != 2 {
+		t.Errorf("refreshCount() = %d, want 2", got)
+	}
+}
+
+func TestFetchAuthorized(t *testing.T) {
+	// the status codes of the requests are recorded by the trace transport
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = &trace.Transport{}
+	defer func() { http.DefaultClient.Transport = transport }()
+
+	var issued atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"token": fmt.Sprintf("t%d", issued.Add(1)), "expires_in": 300})
+	}))
+	defer tokenServer.Close()
+	// the registry rejects the first token, as if it expired
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t2" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer registryServer.Close()
+
+	r := NewPullRunner("", "", PullOptions{TokenRequest: &auth.TokenRequest{Flow: auth.FlowBearer, Realm: tokenServer.URL}})
+	tokens := r.newTokenSource("library/alpine")
+	var tokenFetches int
+	recordToken := func(result.Fetch) { tokenFetches++ }
+	f := r.fetchAuthorized(context.Background(), &remote.Repository{}, tokens, recordToken, result.Fetch{Kind: result.FetchBlob},
+		func(ctx context.Context, repo *remote.Repository) (ocispec.Descriptor, io.ReadCloser, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, registryServer.URL, nil)
+			if err != nil {
+				return ocispec.Descriptor{}, nil, err
+			}
+			resp, err := repo.Client.Do(req)
+			if err != nil {
+				return ocispec.Descriptor{}, nil, err
+			}
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return ocispec.Descriptor{}, nil, &auth.StatusError{StatusCode: resp.StatusCode}
+			}
+			return ocispec.Descriptor{Size: -1}, resp.Body, nil
+		})
+	if f.Err != nil {
+		t.Fatalf("fetchAuthorized() error = %v", f.Err)
+	}
+	if f.Retries != 1 || len(f.Requests) != 2 {
+		t.Errorf("fetchAuthorized() retries = %d, requests = %d, want 1 retry of 2 requests", f.Retries, len(f.Requests))
+	}
+	if tokenFetches != 2 || tokens.refreshCount() != 1 {
+		t.Errorf("got %d token fetches and %d refreshes, want 2 fetches and 1 refresh", tokenFetches, tokens.refreshCount())
+	}
+}
+
+func TestTokenSharedFailure(t *testing.T) {
+	var requests atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	r := NewPullRunner("", "", PullOptions{
+		TokenRequest: &auth.TokenRequest{Flow: auth.FlowBearer, Realm: server.URL},
+		TokenCache:   auth.NewTokenCache(),
+	})
+	type got struct {
+		f        result.Fetch
+		recorded bool
+	}
+	first := make(chan got)
+	go func() {
+		f, _, recorded := r.newTokenSource("library/alpine").get(context.Background())
+		first <- got{f, recorded}
+	}()
+	<-started
+	waiter := make(chan got)
+	go func() {
+		f, _, recorded := r.newTokenSource("library/alpine").get(context.Background())
+		waiter <- got{f, recorded}
+	}()
+	// let the waiter wait for the token in flight
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	if g := <-first; g.f.Err == nil || !g.recorded {
+		t.Errorf("get() of the fetching instance = %v, recorded %v, want a recorded failure", g.f.Err, g.recorded)
+	}
+	if g := <-waiter; g.f.Err == nil || g.recorded {
+		t.Errorf("get() of the waiting instance = %v, recorded %v, want an unrecorded failure", g.f.Err, g.recorded)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+}
+
+func TestStartNewInvalidReference(t *testing.T) {
+	var records []result.Record
+	r := NewPullRunner("", "registry.example.com", PullOptions{Results: recordsFunc(func(record result.Record) {
+		records = append(records, record)
+	})})
+	if err := r.StartNew(context.Background(), asset.Asset{Name: "invalid.json", Data: image.Data{Manifest: "not a reference"}}); err == nil {
+		t.Fatal("StartNew() succeeded, want an error")
+	}
+	if len(records) != 1 || records[0].SuccessCount != 0 || records[0].Errors[result.CategoryOther] != 1 {
+		t.Errorf("StartNew() records = %+v, want a failed record", records)
+	}
+}
+
+func TestStartNewInvalidBlobReference(t *testing.T) {
+	var records []result.Record
+	// nothing listens on the registry, the manifest fetch fails quickly
+	r := NewPullRunner("", "127.0.0.1:1", PullOptions{Results: recordsFunc(func(record result.Record) {
+		records = append(records, record)
+	})})
+	data := asset.Asset{Name: "invalid.json", Data: image.Data{
+		Manifest: "registry.example.com/app:latest",
+		Blobs:    []string{"not a reference"},
+	}}
+	_ = r.StartNew(context.Background(), data)
+	if len(records) != 1 {
+		t.Fatalf("StartNew() wrote %d records, want 1", len(records))
+	}
+	var blobs []result.Fetch
+	for _, f := range records[0].Fetches {
+		if f.Kind == result.FetchBlob {
+			blobs = append(blobs, f)
+		}
+	}
+	if len(blobs) != 1 || blobs[0].Category != result.CategoryOther || blobs[0].Err == nil {
+		t.Errorf("StartNew() blob fetches = %+v, want a failed fetch categorized as %s", blobs, result.CategoryOther)
+	}
+	if got := len(records[0].Fetches); got != records[0].TotalCount {
+		t.Errorf("StartNew() recorded %d fetches, want the total count %d", got, records[0].TotalCount)
+	}
+}
+
+// recordsFunc is a result.Writer calling a function with the records.
+type recordsFunc func(result.Record)
+
+func (f recordsFunc) Write(r result.Record) error {
+	f(r)
+	return nil
+}
+
+func (f recordsFunc) Close() error { return nil }
+
+func TestFetchAuthorizedTokenFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	r := NewPullRunner("", "", PullOptions{TokenRequest: &auth.TokenRequest{Flow: auth.FlowBearer, Realm: server.URL}})
+	var tokenFetches []result.Fetch
+	recordToken := func(f result.Fetch) { tokenFetches = append(tokenFetches, f) }
+	f := r.fetchAuthorized(context.Background(), &remote.Repository{}, r.newTokenSource("library/alpine"), recordToken, result.Fetch{Kind: result.FetchBlob},
+		func(ctx context.Context, repo *remote.Repository) (ocispec.Descriptor, io.ReadCloser, error) {
+			t.Fatal("the blob is fetched without a token")
+			return ocispec.Descriptor{}, nil, nil
+		})
+	// the cause is counted once, by the token fetch
+	if len(tokenFetches) != 1 || tokenFetches[0].Category != result.CategoryServerError {
+		t.Fatalf("token fetches = %+v, want a single fetch failed with %s", tokenFetches, result.CategoryServerError)
+	}
+	if f.Category != result.CategoryToken || f.Duration < 10*time.Millisecond {
+		t.Errorf("fetchAuthorized() category = %q, duration = %v, want %q lasting the token fetch", f.Category, f.Duration, result.CategoryToken)
+	}
+}
@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// ErrStalled is returned when no byte is received for the stall timeout.
+var ErrStalled = errors.New("stalled: no bytes received")
+
+// Timeouts represents the timeouts of the operations of a test instance.
+// A zero timeout means no timeout.
+type Timeouts struct {
+	// Request bounds each request, including its retries and reading the
+	// response body.
+	Request time.Duration
+	// Instance bounds each test instance as a whole.
+	Instance time.Duration
+	// Stall bounds the time without receiving any byte of a response.
+	Stall time.Duration
+}
+
+// withTimeout returns a context bounded by timeout, if any.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// IsTimeout returns whether err is caused by a timeout, as opposed to, for
+// instance, an interrupted run.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrStalled) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// timeoutError annotates err with the timeout which canceled ctx, if any, so
+// that IsTimeout recognizes it even if err only reports the cancellation.
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil || IsTimeout(err) {
+		return err
+	}
+	if cause := context.Cause(ctx); IsTimeout(cause) {
+		return errors.Join(cause, err)
+	}
+	return err
+}
+
+// stallDetector cancels a request when no byte is received for a while.
+type stallDetector struct {
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+// withStallDetector returns a context canceled with ErrStalled if the returned
+// detector is not notified of received bytes within timeout. A zero timeout
+// disables the detection. The returned function stops the detection.
+func withStallDetector(ctx context.Context, timeout time.Duration) (context.Context, *stallDetector, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	if timeout <= 0 {
+		return ctx, nil, func() { cancel(nil) }
+	}
+	d := &stallDetector{
+		timer:   time.AfterFunc(timeout, func() { cancel(ErrStalled) }),
+		timeout: timeout,
+	}
+	return ctx, d, func() {
+		d.timer.Stop()
+		cancel(nil)
+	}
+}
+
+// reader returns a reader notifying the detector of the bytes read from r.
+func (d *stallDetector) reader(r io.Reader) io.Reader {
+	if d == nil {
+		return r
+	}
+	return &stallReader{r: r, d: d}
+}
+
+type stallReader struct {
+	r io.Reader
+	d *stallDetector
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.d.timer.Reset(s.d.timeout)
+	}
+	return n, err
+}
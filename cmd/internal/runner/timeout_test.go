@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+)
+
+func TestFetchTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		if r.URL.Path == "/stall" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	open := func(path string) func(ctx context.Context) (ocispec.Descriptor, io.ReadCloser, error) {
+		return func(ctx context.Context) (ocispec.Descriptor, io.ReadCloser, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+			if err != nil {
+				return ocispec.Descriptor{}, nil, err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return ocispec.Descriptor{}, nil, err
+			}
+			return ocispec.Descriptor{}, resp.Body, nil
+		}
+	}
+
+	tests := []struct {
+		name        string
+		timeouts    Timeouts
+		path        string
+		cancel      bool
+		wantErr     error
+		wantTimeout bool
+	}{
+		{name: "Complete response", timeouts: Timeouts{Stall: time.Second}, path: "/"},
+		{name: "Stalled response", timeouts: Timeouts{Stall: 50 * time.Millisecond}, path: "/stall", wantErr: ErrStalled, wantTimeout: true},
+		{name: "Request timeout", timeouts: Timeouts{Request: 50 * time.Millisecond}, path: "/stall", wantErr: context.DeadlineExceeded, wantTimeout: true},
+		{name: "Interrupted run", timeouts: Timeouts{Stall: time.Minute}, path: "/stall", cancel: true, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewPullRunner("", "", PullOptions{Timeouts: tt.timeouts})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(50*time.Millisecond, cancel)
+			}
+			f := r.fetch(ctx, result.Fetch{Kind: result.FetchBlob}, open(tt.path))
+			if f.Size != int64(len("partial")) {
+				t.Errorf("fetch() size = %d, want %d", f.Size, len("partial"))
+			}
+			err := f.Err
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("fetch() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("fetch() error = %v, want %v", err, tt.wantErr)
+			}
+			if IsTimeout(err) != tt.wantTimeout {
+				t.Errorf("IsTimeout(%v) = %v, want %v", err, IsTimeout(err), tt.wantTimeout)
+			}
+		})
+	}
+}
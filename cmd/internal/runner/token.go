@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	tokenauth "github.com/billy-playground/registry-load-tester/internal/auth"
+	"github.com/billy-playground/registry-load-tester/internal/retry"
+	"github.com/billy-playground/registry-load-tester/internal/trace"
+)
+
+// tokenSource provides the token of the repository of an instance, through
+// the shared token cache or the instance's own one, so that the token is
+// refreshed at its first use within the expiry margin and once rejected by
+// the registry, with the refresh token issued by the OAuth2 grants.
+type tokenSource struct {
+	request    tokenauth.TokenRequest
+	cache      *tokenauth.TokenCache
+	repository string
+	timeout    time.Duration
+	// refreshes counts the tokens refreshed by the instance.
+	refreshes atomic.Int32
+}
+
+// newTokenSource returns the token source of an instance pulling from the
+// repository.
+func (r *PullRunner) newTokenSource(repository string) *tokenSource {
+	request := *r.opts.TokenRequest
+	request.Scopes = []string{tokenauth.RepositoryScope(repository)}
+	cache := r.opts.TokenCache
+	if cache == nil {
+		cache = tokenauth.NewTokenCache()
+	}
+	return &tokenSource{
+		request:    request,
+		cache:      cache,
+		repository: repository,
+		timeout:    r.opts.Timeouts.Request,
+	}
+}
+
+// get returns the access token, requesting it within the request timeout if
+// it is not cached, expiring or rejected. It returns the fetch of the token,
+// the access token, and whether the fetch is recorded, i.e. whether the token
+// was requested by the instance. The instances waiting for the token of
+// another one do not record its fetch, even if it failed.
+func (s *tokenSource) get(ctx context.Context) (result.Fetch, string, bool) {
+	f := result.Fetch{Kind: result.FetchToken, Repository: s.repository, Timestamp: time.Now()}
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+	ctx, counter := retry.WithCounter(ctx)
+	ctx, recorder := trace.WithRecorder(ctx)
+
+	token, fetched, err := s.cache.Get(ctx, s.request.Scopes[0], func(previous *tokenauth.Token) (tokenauth.Token, error) {
+		if previous == nil {
+			return tokenauth.FetchToken(ctx, s.request)
+		}
+		s.refreshes.Add(1)
+		token, err := tokenauth.FetchToken(ctx, s.request.Refresh(previous.RefreshToken))
+		if err == nil && token.RefreshToken == "" {
+			// the refresh token is kept if no new one is issued
+			token.RefreshToken = previous.RefreshToken
+		}
+		return token, err
+	})
+
+	f.Err = timeoutError(ctx, err)
+	f.Duration = time.Since(f.Timestamp)
+	f.Retries = counter.Retries()
+	f.Requests = recorder.Timings()
+	f.Status, f.RedirectHost = responseOf(f.Requests)
+	f.Category = Category(f.Err, lastStatus(f.Requests))
+	return f, token.AccessToken, fetched
+}
+
+// tokenFailed returns the fetch f failed as the token it needed, fetched by
+// tf, could not be got. It lasts as long as the token fetch, and is
+// categorized as CategoryToken for the cause not to be counted twice.
+func tokenFailed(f result.Fetch, tf result.Fetch) result.Fetch {
+	f.Timestamp = tf.Timestamp
+	f.Duration = tf.Duration
+	f.Err = fmt.Errorf("failed to get token: %w", tf.Err)
+	f.Category = result.CategoryToken
+	return f
+}
+
+// invalidate invalidates the access token rejected by the registry, for the
+// next get to refresh it.
+func (s *tokenSource) invalidate(accessToken string) {
+	s.cache.Invalidate(s.request.Scopes[0], accessToken)
+}
+
+// refreshCount returns the number of tokens refreshed by the instance, 0 if
+// the tokens are not requested by the tool.
+func (s *tokenSource) refreshCount() int {
+	if s == nil {
+		return 0
+	}
+	return int(s.refreshes.Load())
+}
+
+// authorizedClient sends the requests with an Authorization header. Unlike
+// auth.Client of oras, it does not follow the challenges of the rejected
+// requests, the tokens being refreshed by the token source instead.
+type authorizedClient struct {
+	authorization string
+}
+
+// Do sends the request with the Authorization header.
+func (c *authorizedClient) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", c.authorization)
+	return http.DefaultClient.Do(req)
+}
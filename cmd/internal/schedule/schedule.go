@@ -0,0 +1,224 @@
+package schedule
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Arrival describes when the instances of a run start.
+type Arrival interface {
+	// Offsets returns the start offsets of at most count instances, relative
+	// to the start of the run, in ascending order.
+	Offsets(count int, r *rand.Rand) []time.Duration
+}
+
+// Burst starts Size instances every Interval. All the instances start at once
+// if Size or Interval is not positive.
+type Burst struct {
+	Size     int
+	Interval time.Duration
+}
+
+// Offsets returns the start offsets of the batches.
+func (b Burst) Offsets(count int, _ *rand.Rand) []time.Duration {
+	offsets := make([]time.Duration, count)
+	if b.Size <= 0 || b.Interval <= 0 {
+		return offsets
+	}
+	for i := range offsets {
+		offsets[i] = time.Duration(i/b.Size) * b.Interval
+	}
+	return offsets
+}
+
+// Constant starts instances at a constant rate.
+type Constant struct {
+	// Rate is the number of instances started per second.
+	Rate float64
+}
+
+// Offsets returns evenly spaced start offsets.
+func (c Constant) Offsets(count int, _ *rand.Rand) []time.Duration {
+	offsets := make([]time.Duration, count)
+	for i := range offsets {
+		offsets[i] = seconds(float64(i) / c.Rate)
+	}
+	return offsets
+}
+
+// Poisson starts instances as a Poisson process, i.e. with independent and
+// exponentially distributed inter-arrival times.
+type Poisson struct {
+	// Rate is the mean number of instances started per second.
+	Rate float64
+}
+
+// Offsets returns the start offsets of a Poisson process, starting with the
+// first instance at offset 0.
+func (p Poisson) Offsets(count int, r *rand.Rand) []time.Duration {
+	offsets := make([]time.Duration, count)
+	var elapsed float64
+	for i := 1; i < count; i++ {
+		elapsed += r.ExpFloat64() / p.Rate
+		offsets[i] = seconds(elapsed)
+	}
+	return offsets
+}
+
+// Segment is a phase of a Profile during which the rate changes linearly.
+type Segment struct {
+	Duration time.Duration
+	// From and To are the number of instances started per second at the
+	// beginning and the end of the segment.
+	From float64
+	To   float64
+}
+
+// Profile starts instances at a rate varying over time, e.g. ramps, steps and
+// spikes. The profile ends after its last segment, even if fewer instances
+// than requested have been started.
+type Profile struct {
+	Segments []Segment
+}
+
+// Offsets returns the start offsets of the instances, the k-th instance
+// starting once k instances are due according to the rate profile.
+func (p Profile) Offsets(count int, _ *rand.Rand) []time.Duration {
+	offsets := make([]time.Duration, 0, count)
+	var base float64  // instances due at the beginning of the segment
+	var start float64 // beginning of the segment in seconds
+	for _, segment := range p.Segments {
+		duration := segment.Duration.Seconds()
+		area := (segment.From + segment.To) / 2 * duration
+		// the number of instances due t seconds into the segment is
+		// a*t^2 + b*t, solved for t below
+		a := (segment.To - segment.From) / (2 * duration)
+		b := segment.From
+		for len(offsets) < count {
+			need := float64(len(offsets)) - base
+			if need > area {
+				break
+			}
+			var t float64
+			if need > 0 {
+				t = 2 * need / (b + math.Sqrt(b*b+4*a*need))
+			}
+			offsets = append(offsets, seconds(start+t))
+		}
+		base += area
+		start += duration
+	}
+	return offsets
+}
+
+// Duration returns the total duration of the profile.
+func (p Profile) Duration() time.Duration {
+	var d time.Duration
+	for _, segment := range p.Segments {
+		d += segment.Duration
+	}
+	return d
+}
+
+// Stats describes how closely a run followed its schedule.
+type Stats struct {
+	// Started is the number of started calls.
+	Started int
+	// Delayed is the number of calls which started late because all the
+	// workers were busy, i.e. the client was the bottleneck.
+	Delayed int
+	// MaxLag is the longest delay of a call behind its schedule.
+	MaxLag time.Duration
+}
+
+// Run calls fn for every offset once the offset has elapsed since the start of
+// the run, and waits for all calls to return. At most workers calls are active
+// at once; a call due while all the workers are busy waits for a free worker.
+// workers of 0 means no limit. No call starts once ctx is done.
+func Run(ctx context.Context, offsets []time.Duration, workers int, fn func(i int)) Stats {
+	var slots chan struct{}
+	if workers > 0 {
+		slots = make(chan struct{}, workers)
+	}
+
+	var stats Stats
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, offset := range offsets {
+		scheduled := start.Add(offset)
+		if !sleepUntil(ctx, scheduled) {
+			break
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			default:
+				// all workers are busy
+				stats.Delayed++
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		stats.MaxLag = max(stats.MaxLag, time.Since(scheduled))
+		stats.Started++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if slots != nil {
+				defer func() { <-slots }()
+			}
+			fn(i)
+		}()
+	}
+	wg.Wait()
+	return stats
+}
+
+// sleepUntil waits until t, and returns false if ctx is done first.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	toWait := time.Until(t)
+	if toWait <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(toWait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Soak keeps concurrency calls of fn active until the duration elapses, calling
+// fn again as each call returns. No call starts after the deadline or once ctx
+// is done; Soak waits for the active calls to return, for the run to stop
+// cleanly without aborting them. Each call gets a unique, increasing index.
+func Soak(ctx context.Context, duration time.Duration, concurrency int, fn func(i int)) {
+	deadline := time.Now().Add(duration)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil && time.Now().Before(deadline) {
+				fn(int(next.Add(1) - 1))
+			}
+		}()
+	}
+	wg.Wait()
+}
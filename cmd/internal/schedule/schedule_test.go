@@ -0,0 +1,159 @@
+package schedule
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOffsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		arrival Arrival
+		want    []time.Duration
+	}{
+		{
+			name:    "All at once",
+			arrival: Burst{},
+			want:    []time.Duration{0, 0, 0, 0},
+		},
+		{
+			name:    "Batches",
+			arrival: Burst{Size: 3, Interval: time.Second},
+			want:    []time.Duration{0, 0, 0, time.Second},
+		},
+		{
+			name: "Steps",
+			arrival: Profile{Segments: []Segment{
+				{Duration: time.Second, From: 2, To: 2},
+				{Duration: time.Second, From: 4, To: 4},
+			}},
+			want: []time.Duration{0, 500 * time.Millisecond, time.Second, 1250 * time.Millisecond, 1500 * time.Millisecond, 1750 * time.Millisecond, 2 * time.Second},
+		},
+		{
+			name: "Linear ramp from zero",
+			// t^2 instances are due after t seconds
+			arrival: Profile{Segments: []Segment{{Duration: 2 * time.Second, From: 0, To: 4}}},
+			want:    []time.Duration{0, time.Second, 1414213562, 1732050807, 2 * time.Second},
+		},
+		{
+			name:    "Constant rate",
+			arrival: Constant{Rate: 4},
+			want:    []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.arrival.Offsets(len(tt.want), nil); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Offsets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileEnds(t *testing.T) {
+	profile := Profile{Segments: []Segment{{Duration: time.Second, From: 10, To: 10}}}
+	if got := len(profile.Offsets(100, nil)); got != 11 {
+		t.Errorf("Offsets() returned %d offsets, want 11", got)
+	}
+	if got := len(profile.Offsets(5, nil)); got != 5 {
+		t.Errorf("Offsets() returned %d offsets, want 5", got)
+	}
+}
+
+func TestPoissonOffsets(t *testing.T) {
+	const count = 10000
+	offsets := Poisson{Rate: 100}.Offsets(count, rand.New(rand.NewSource(1)))
+	if offsets[0] != 0 {
+		t.Errorf("Offsets() first offset = %v, want 0", offsets[0])
+	}
+	for i := 1; i < count; i++ {
+		if offsets[i] < offsets[i-1] {
+			t.Fatalf("Offsets() are not ascending at %d: %v < %v", i, offsets[i], offsets[i-1])
+		}
+	}
+	// the mean inter-arrival time is 1/rate
+	if mean := offsets[count-1] / (count - 1); mean < 9*time.Millisecond || mean > 11*time.Millisecond {
+		t.Errorf("Offsets() mean inter-arrival time = %v, want about 10ms", mean)
+	}
+}
+
+func TestSoak(t *testing.T) {
+	const concurrency = 3
+	var mu sync.Mutex
+	var active, maxActive, calls int
+	start := time.Now()
+	deadline := start.Add(100 * time.Millisecond)
+	Soak(context.Background(), 100*time.Millisecond, concurrency, func(int) {
+		if time.Now().After(deadline) {
+			t.Error("Soak() started a call after the deadline")
+		}
+		mu.Lock()
+		active++
+		calls++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+	})
+	if maxActive != concurrency {
+		t.Errorf("Soak() kept %d calls active, want %d", maxActive, concurrency)
+	}
+	if active != 0 {
+		t.Errorf("Soak() returned with %d calls active, want the calls in flight to complete", active)
+	}
+	if calls <= concurrency {
+		t.Errorf("Soak() made %d calls, want more than %d", calls, concurrency)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Soak() returned after %v, before the deadline", elapsed)
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	const workers = 2
+	var mu sync.Mutex
+	var active, maxActive int
+	stats := Run(context.Background(), make([]time.Duration, 6), workers, func(int) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+	})
+	if maxActive != workers {
+		t.Errorf("Run() kept %d calls active, want %d", maxActive, workers)
+	}
+	if stats.Started != 6 || stats.Delayed == 0 {
+		t.Errorf("Run() stats = %+v, want 6 started and some delayed", stats)
+	}
+	if stats.MaxLag < 40*time.Millisecond {
+		t.Errorf("Run() max lag = %v, want at least 40ms", stats.MaxLag)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	offsets := []time.Duration{0, 0, 50 * time.Millisecond, time.Hour}
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	stats := Run(ctx, offsets, 0, func(int) {
+		calls.Add(1)
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Run() returned after %v, want right after cancellation", elapsed)
+	}
+	if stats.Started != 2 || calls.Load() != 2 {
+		t.Errorf("Run() started %d calls, made %d, want 2", stats.Started, calls.Load())
+	}
+}
@@ -0,0 +1,171 @@
+package selector
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+)
+
+// Default parameters of the distributions.
+const (
+	DefaultZipfExponent = 1.0
+	DefaultWeightField  = "weight"
+	// RankField is the numeric field of the asset JSON ranking the assets of
+	// the zipf distribution, from 1 for the hottest.
+	RankField = "rank"
+)
+
+// Selector selects the index of the next asset to pull.
+type Selector interface {
+	Select(r *rand.Rand) int
+}
+
+// New creates a selector over the assets from a distribution spec, which can be
+// one of the following:
+//
+//	uniform: every asset is equally likely to be picked
+//	zipf[=<exponent>]: the k-th asset is picked with a probability proportional to 1/k^exponent
+//	weight[=<field>]: assets are picked proportionally to a numeric field of the asset JSON
+//	size: assets are picked proportionally to their size
+//
+// The assets of the zipf distribution are ranked by their rank field if they
+// all have one, or else in a random order drawn from seed.
+func New(spec string, assets []asset.Asset, seed int64) (Selector, error) {
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("no assets to select from")
+	}
+	name, param, hasParam := strings.Cut(spec, "=")
+	switch name {
+	case "", "uniform":
+		if hasParam {
+			return nil, fmt.Errorf("uniform distribution takes no parameter")
+		}
+		return uniform(len(assets)), nil
+	case "zipf":
+		exponent := DefaultZipfExponent
+		if hasParam {
+			var err error
+			if exponent, err = strconv.ParseFloat(param, 64); err != nil {
+				return nil, fmt.Errorf("invalid zipf exponent %q: %v", param, err)
+			}
+			if exponent <= 0 || math.IsInf(exponent, 0) || math.IsNaN(exponent) {
+				return nil, fmt.Errorf("zipf exponent must be greater than 0")
+			}
+		}
+		order, err := zipfOrder(assets, seed)
+		if err != nil {
+			return nil, err
+		}
+		weights := make([]float64, len(assets))
+		for k, i := range order {
+			weights[i] = 1 / math.Pow(float64(k+1), exponent)
+		}
+		return newWeighted(weights)
+	case "weight":
+		field := DefaultWeightField
+		if hasParam {
+			if param == "" {
+				return nil, fmt.Errorf("weight field name is empty")
+			}
+			field = param
+		}
+		weights := make([]float64, len(assets))
+		for i, a := range assets {
+			weight, ok := a.Number(field)
+			if !ok {
+				return nil, fmt.Errorf("asset %s has no numeric field %q", a.Name, field)
+			}
+			weights[i] = weight
+		}
+		return newWeighted(weights)
+	case "size":
+		if hasParam {
+			return nil, fmt.Errorf("size distribution takes no parameter")
+		}
+		weights := make([]float64, len(assets))
+		for i, a := range assets {
+			weights[i] = float64(a.Size)
+		}
+		return newWeighted(weights)
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", name)
+	}
+}
+
+// zipfOrder returns the indexes of the assets from the hottest to the coldest,
+// sorted by their rank field if they all have one, or shuffled with seed if
+// none has.
+func zipfOrder(assets []asset.Asset, seed int64) ([]int, error) {
+	order := make([]int, len(assets))
+	for i := range order {
+		order[i] = i
+	}
+	ranks := make([]float64, len(assets))
+	var missing []string
+	for i, a := range assets {
+		var ok bool
+		if ranks[i], ok = a.Number(RankField); !ok {
+			missing = append(missing, a.Name)
+		}
+	}
+	switch len(missing) {
+	case 0:
+		sort.SliceStable(order, func(i, j int) bool {
+			return ranks[order[i]] < ranks[order[j]]
+		})
+	case len(assets):
+		r := rand.New(rand.NewSource(seed))
+		r.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+	default:
+		return nil, fmt.Errorf("asset %s has no numeric field %q", missing[0], RankField)
+	}
+	return order, nil
+}
+
+// uniform selects every index with the same probability.
+type uniform int
+
+// Select returns a uniformly random index.
+func (u uniform) Select(r *rand.Rand) int {
+	return r.Intn(int(u))
+}
+
+// weighted selects indexes proportionally to their weights.
+type weighted struct {
+	// cumulative holds the cumulative sum of the weights.
+	cumulative []float64
+}
+
+func newWeighted(weights []float64) (*weighted, error) {
+	cumulative := make([]float64, len(weights))
+	var total float64
+	for i, w := range weights {
+		if w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+			return nil, fmt.Errorf("invalid weight %v at index %d", w, i)
+		}
+		total += w
+		cumulative[i] = total
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("total weight must be greater than 0")
+	}
+	return &weighted{cumulative: cumulative}, nil
+}
+
+// Select returns a random index picked proportionally to its weight.
+func (w *weighted) Select(r *rand.Rand) int {
+	total := w.cumulative[len(w.cumulative)-1]
+	target := r.Float64() * total
+	// find the first index whose cumulative weight exceeds the target so that
+	// zero-weight entries are never picked
+	return sort.Search(len(w.cumulative), func(i int) bool {
+		return w.cumulative[i] > target
+	})
+}
@@ -0,0 +1,108 @@
+package selector
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/image"
+)
+
+func TestNew(t *testing.T) {
+	assets := []asset.Asset{
+		{Name: "a", Data: image.Data{Size: 0}, Fields: map[string]json.RawMessage{"weight": json.RawMessage("1"), "hits": json.RawMessage("0"), "rank": json.RawMessage("1")}},
+		{Name: "b", Data: image.Data{Size: 300}, Fields: map[string]json.RawMessage{"weight": json.RawMessage("3"), "hits": json.RawMessage("5"), "rank": json.RawMessage("2")}},
+	}
+	tests := []struct {
+		name      string
+		spec      string
+		wantRatio float64 // expected share of the second asset
+		wantErr   bool
+	}{
+		{name: "Default is uniform", spec: "", wantRatio: 0.5},
+		{name: "Uniform", spec: "uniform", wantRatio: 0.5},
+		{name: "Zipf with default exponent", spec: "zipf", wantRatio: 1.0 / 3},
+		{name: "Zipf with exponent", spec: "zipf=2", wantRatio: 0.2},
+		{name: "Default weight field", spec: "weight", wantRatio: 0.75},
+		{name: "Custom weight field", spec: "weight=hits", wantRatio: 1},
+		{name: "Size", spec: "size", wantRatio: 1},
+		{name: "Invalid: unknown distribution", spec: "pareto", wantErr: true},
+		{name: "Invalid: zipf exponent is zero", spec: "zipf=0", wantErr: true},
+		{name: "Invalid: non-numeric zipf exponent", spec: "zipf=abc", wantErr: true},
+		{name: "Invalid: missing weight field", spec: "weight=missing", wantErr: true},
+		{name: "Invalid: uniform with parameter", spec: "uniform=1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := New(tt.spec, assets, 1)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			r := rand.New(rand.NewSource(1))
+			const n = 20000
+			var hits int
+			for range n {
+				if selector.Select(r) == 1 {
+					hits++
+				}
+			}
+			if got := float64(hits) / n; got < tt.wantRatio-0.02 || got > tt.wantRatio+0.02 {
+				t.Errorf("Select() picked the second asset %.3f of the time, want %.3f", got, tt.wantRatio)
+			}
+		})
+	}
+}
+
+func TestZipfOrder(t *testing.T) {
+	rank := func(name, rank string) asset.Asset {
+		a := asset.Asset{Name: name, Fields: map[string]json.RawMessage{}}
+		if rank != "" {
+			a.Fields[RankField] = json.RawMessage(rank)
+		}
+		return a
+	}
+
+	// the ranks of the assets take precedence over their order
+	order, err := zipfOrder([]asset.Asset{rank("a", "3"), rank("b", "1"), rank("c", "2")}, 1)
+	if err != nil {
+		t.Fatalf("zipfOrder() error = %v", err)
+	}
+	if !reflect.DeepEqual(order, []int{1, 2, 0}) {
+		t.Errorf("zipfOrder() = %v, want [1 2 0]", order)
+	}
+
+	// some assets without rank cannot be ranked
+	if _, err := zipfOrder([]asset.Asset{rank("a", "1"), rank("b", "")}, 1); err == nil {
+		t.Error("zipfOrder() succeeded with an unranked asset, want an error")
+	}
+
+	// the assets without ranks are shuffled with the seed
+	var assets []asset.Asset
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		assets = append(assets, rank(name, ""))
+	}
+	hottest := map[int]bool{}
+	for seed := range int64(20) {
+		order, err := zipfOrder(assets, seed)
+		if err != nil {
+			t.Fatalf("zipfOrder() error = %v", err)
+		}
+		again, _ := zipfOrder(assets, seed)
+		if !reflect.DeepEqual(order, again) {
+			t.Fatalf("zipfOrder() = %v then %v with the same seed", order, again)
+		}
+		hottest[order[0]] = true
+	}
+	if len(hottest) < 2 {
+		t.Errorf("zipfOrder() ranked %v first with every seed, want a seeded order", hottest)
+	}
+}
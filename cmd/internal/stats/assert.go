@@ -0,0 +1,168 @@
+package stats
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// comparators are the comparators of the assertions, the longest first so
+// that they are matched first.
+var comparators = []string{"<=", ">=", "<", ">"}
+
+// Metrics of the assertions besides the latencies, i.e. min, mean, max and
+// the percentiles such as p99 or p99.9.
+const (
+	MetricErrorRate    = "error_rate"
+	MetricSuccessRate  = "success_rate"
+	MetricCount        = "count"
+	MetricOpsPerSecond = "ops_per_second"
+	MetricMBPerSecond  = "mb_per_second"
+)
+
+// Assertion is a threshold on a metric of an operation of a run, in the
+// format [<operation>.]<metric><comparator><value>, e.g. blob.p99<2s or
+// error_rate<0.5%.
+type Assertion struct {
+	Expr string
+	// Operation is the asserted operation, empty for the operation of the
+	// instances, i.e. auth or pull.
+	Operation  string
+	Metric     string
+	Comparator string
+	// Value is the threshold, in nanoseconds for the latencies and as a
+	// fraction for the rates.
+	Value float64
+}
+
+// ParseAssertion parses an assertion.
+func ParseAssertion(expr string) (Assertion, error) {
+	a := Assertion{Expr: expr}
+	i := strings.IndexAny(expr, "<>")
+	if i < 0 {
+		return Assertion{}, fmt.Errorf("assertion %q should be in the format [<operation>.]<metric><comparator><value>", expr)
+	}
+	for _, c := range comparators {
+		if strings.HasPrefix(expr[i:], c) {
+			a.Comparator = c
+			break
+		}
+	}
+	left := strings.TrimSpace(expr[:i])
+	value := strings.TrimSpace(expr[i+len(a.Comparator):])
+	a.Metric = left
+	if op, metric, ok := strings.Cut(left, "."); ok && slices.Contains(operationOrder, op) {
+		a.Operation, a.Metric = op, metric
+	}
+
+	var err error
+	switch {
+	case a.Metric == MetricErrorRate || a.Metric == MetricSuccessRate:
+		a.Value, err = parseRate(value)
+	case a.Metric == MetricCount || a.Metric == MetricOpsPerSecond || a.Metric == MetricMBPerSecond:
+		a.Value, err = strconv.ParseFloat(value, 64)
+	case isLatency(a.Metric):
+		var d time.Duration
+		d, err = time.ParseDuration(value)
+		a.Value = float64(d)
+	default:
+		return Assertion{}, fmt.Errorf("unknown metric %q in assertion %q, expecting min, mean, max, p<percentile>, %s, %s, %s, %s or %s",
+			a.Metric, expr, MetricErrorRate, MetricSuccessRate, MetricCount, MetricOpsPerSecond, MetricMBPerSecond)
+	}
+	if err != nil {
+		return Assertion{}, fmt.Errorf("invalid value %q in assertion %q: %w", value, expr, err)
+	}
+	return a, nil
+}
+
+// isLatency returns whether the metric is a latency.
+func isLatency(metric string) bool {
+	switch metric {
+	case "min", "mean", "max":
+		return true
+	}
+	q, ok := quantile(metric)
+	return ok && q > 0 && q <= 1
+}
+
+// quantile returns the quantile of a percentile metric, e.g. 0.99 for p99.
+func quantile(metric string) (float64, bool) {
+	p, ok := strings.CutPrefix(metric, "p")
+	if !ok {
+		return 0, false
+	}
+	q, err := strconv.ParseFloat(p, 64)
+	return q / 100, err == nil
+}
+
+// parseRate parses a rate either as a percentage, e.g. 0.5%, or a fraction.
+func parseRate(value string) (float64, error) {
+	if p, ok := strings.CutSuffix(value, "%"); ok {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		return v / 100, err
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// Check checks the assertion against the operations of a run which took
+// elapsed time, operation being the operation of its instances. It returns
+// the formatted actual value of the metric and whether the assertion holds.
+// An assertion on an operation which was not recorded does not hold.
+func (a Assertion) Check(ops []Operation, elapsed time.Duration, operation string) (string, bool) {
+	if a.Operation != "" {
+		operation = a.Operation
+	}
+	i := slices.IndexFunc(ops, func(op Operation) bool { return op.Name == operation })
+	if i < 0 {
+		return fmt.Sprintf("no %s recorded", operation), false
+	}
+	op := ops[i]
+
+	var actual float64
+	var formatted string
+	switch a.Metric {
+	case MetricErrorRate:
+		actual = 1 - op.SuccessRate()
+		formatted = fmt.Sprintf("%.2f%%", actual*100)
+	case MetricSuccessRate:
+		actual = op.SuccessRate()
+		formatted = fmt.Sprintf("%.2f%%", actual*100)
+	case MetricCount:
+		actual = float64(op.Count)
+		formatted = strconv.FormatInt(op.Count, 10)
+	case MetricOpsPerSecond:
+		actual = perSecond(float64(op.Count), elapsed)
+		formatted = fmt.Sprintf("%.2f", actual)
+	case MetricMBPerSecond:
+		actual = perSecond(float64(op.Bytes)/1e6, elapsed)
+		formatted = fmt.Sprintf("%.2f", actual)
+	default:
+		var d time.Duration
+		switch a.Metric {
+		case "min":
+			d = op.Latency.Min()
+		case "mean":
+			d = op.Latency.Mean()
+		case "max":
+			d = op.Latency.Max()
+		default:
+			q, _ := quantile(a.Metric)
+			d = op.Latency.Quantile(q)
+		}
+		actual = float64(d)
+		formatted = FormatDuration(d)
+	}
+
+	switch a.Comparator {
+	case "<":
+		return formatted, actual < a.Value
+	case "<=":
+		return formatted, actual <= a.Value
+	case ">":
+		return formatted, actual > a.Value
+	default:
+		return formatted, actual >= a.Value
+	}
+}
@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+)
+
+func TestParseAssertion(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    Assertion
+		wantErr bool
+	}{
+		{expr: "blob.p99<2s", want: Assertion{Operation: "blob", Metric: "p99", Comparator: "<", Value: 2e9}},
+		{expr: "p99.9 <= 500ms", want: Assertion{Metric: "p99.9", Comparator: "<=", Value: 5e8}},
+		{expr: "error_rate<0.5%", want: Assertion{Metric: "error_rate", Comparator: "<", Value: 0.005}},
+		{expr: "pull.success_rate>=0.99", want: Assertion{Operation: "pull", Metric: "success_rate", Comparator: ">=", Value: 0.99}},
+		{expr: "manifest.ops_per_second>10", want: Assertion{Operation: "manifest", Metric: "ops_per_second", Comparator: ">", Value: 10}},
+		{expr: "p99", wantErr: true},
+		{expr: "blob.p99<fast", wantErr: true},
+		{expr: "p101<1s", wantErr: true},
+		{expr: "latency<1s", wantErr: true},
+		{expr: "push.p99<1s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseAssertion(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAssertion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			tt.want.Expr = tt.expr
+			if got != tt.want {
+				t.Errorf("ParseAssertion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssertionCheck(t *testing.T) {
+	s := NewSummary()
+	for i := range 10 {
+		r := result.Record{Operation: result.OperationPull, Duration: time.Second, TotalCount: 1, SuccessCount: 1}
+		blob := result.Fetch{Kind: result.FetchBlob, Duration: time.Duration(i+1) * 100 * time.Millisecond, Size: 1e6}
+		if i == 0 {
+			r.SuccessCount = 0
+			blob.Err = errors.New("timeout")
+		}
+		r.Fetches = []result.Fetch{blob}
+		s.Write(r)
+	}
+	ops := s.Operations()
+
+	tests := []struct {
+		expr   string
+		actual string
+		ok     bool
+	}{
+		{"blob.p99<2s", "1s", true},
+		{"blob.max<1s", "1s", false},
+		{"error_rate<5%", "10.00%", false},
+		{"blob.error_rate<=10%", "10.00%", true},
+		{"blob.mb_per_second>=2", "2.00", true},
+		{"count>=10", "10", true},
+		{"auth.p99<1s", "no auth recorded", false},
+	}
+	for _, tt := range tests {
+		a, err := ParseAssertion(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseAssertion(%q) error = %v", tt.expr, err)
+		}
+		actual, ok := a.Check(ops, 5*time.Second, result.OperationPull)
+		if actual != tt.actual || ok != tt.ok {
+			t.Errorf("Check(%q) = %s, %v, want %s, %v", tt.expr, actual, ok, tt.actual, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package stats
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+	"time"
+)
+
+// subBucketBits is the number of bits of the sub-buckets of each power of 2,
+// which bounds the relative error of the recorded values to 1/2^subBucketBits.
+const (
+	subBucketBits  = 7
+	subBucketCount = 1 << subBucketBits
+)
+
+// Histogram records durations in log-linear buckets, like HDR histograms do:
+// each power of 2 is split into subBucketCount linear buckets, so that the
+// quantiles are accurate to less than 1% whatever the magnitude of the values.
+// A Histogram is not safe for concurrent use.
+type Histogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// bucketIndex returns the index of the bucket of the value.
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits - 1
+	return (shift+1)*subBucketCount + int(v>>shift) - subBucketCount
+}
+
+// bucketRange returns the lowest and highest values of the bucket.
+func bucketRange(index int) (int64, int64) {
+	if index < subBucketCount {
+		return int64(index), int64(index)
+	}
+	shift := index/subBucketCount - 1
+	mantissa := int64(index%subBucketCount + subBucketCount)
+	return mantissa << shift, (mantissa+1)<<shift - 1
+}
+
+// Record records a duration. Negative durations are recorded as 0.
+func (h *Histogram) Record(d time.Duration) {
+	d = max(d, 0)
+	index := bucketIndex(int64(d))
+	if index >= len(h.counts) {
+		h.counts = append(h.counts, make([]int64, index+1-len(h.counts))...)
+	}
+	h.counts[index]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Merge adds the durations recorded by other.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]int64, len(other.counts)-len(h.counts))...)
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	h.max = max(h.max, other.max)
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// clone returns a copy of the histogram.
+func (h *Histogram) clone() Histogram {
+	c := *h
+	c.counts = slices.Clone(h.counts)
+	return c
+}
+
+// Count returns the number of recorded durations.
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+// Min returns the lowest recorded duration.
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the highest recorded duration.
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Mean returns the mean of the recorded durations.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Quantile returns the duration below which the fraction q of the recorded
+// durations fall, e.g. 0.99 for the 99th percentile.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.count)))
+	rank = min(max(rank, 1), h.count)
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			low, high := bucketRange(i)
+			v := time.Duration(low + (high-low)/2)
+			return min(max(v, h.min), h.max)
+		}
+	}
+	return h.max
+}
@@ -0,0 +1,65 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBucketRange(t *testing.T) {
+	for _, v := range []int64{0, 1, 127, 128, 255, 256, 1000, 123456789, math.MaxInt64} {
+		low, high := bucketRange(bucketIndex(v))
+		if v < low || v > high {
+			t.Errorf("bucketRange(bucketIndex(%d)) = [%d, %d], want containing %d", v, low, high, v)
+		}
+		if high-low > max(low/subBucketCount, 0) {
+			t.Errorf("bucket [%d, %d] of %d is too wide", low, high, v)
+		}
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h Histogram
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{q: 0, want: time.Millisecond},
+		{q: 0.5, want: 5 * time.Second},
+		{q: 0.9, want: 9 * time.Second},
+		{q: 0.99, want: 9900 * time.Millisecond},
+		{q: 1, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		got := h.Quantile(tt.q)
+		if diff := math.Abs(float64(got-tt.want)) / float64(tt.want); diff > 0.01 {
+			t.Errorf("Quantile(%v) = %v, want %v within 1%%", tt.q, got, tt.want)
+		}
+	}
+	if got := h.Count(); got != 10000 {
+		t.Errorf("Count() = %d, want 10000", got)
+	}
+	if got, want := h.Mean(), 5000500*time.Microsecond; got != want {
+		t.Errorf("Mean() = %v, want %v", got, want)
+	}
+	if h.Min() != time.Millisecond || h.Max() != 10*time.Second {
+		t.Errorf("Min(), Max() = %v, %v, want 1ms, 10s", h.Min(), h.Max())
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	var a, b Histogram
+	a.Record(2 * time.Second)
+	b.Record(time.Millisecond)
+	b.Record(3 * time.Second)
+	a.Merge(&b)
+	if a.Count() != 3 || a.Min() != time.Millisecond || a.Max() != 3*time.Second {
+		t.Errorf("merged histogram has count %d, min %v, max %v", a.Count(), a.Min(), a.Max())
+	}
+	if got := a.Quantile(0.5); math.Abs(float64(got-2*time.Second)) > float64(2*time.Second)/100 {
+		t.Errorf("Quantile(0.5) = %v, want 2s", got)
+	}
+}
@@ -0,0 +1,347 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/internal/trace"
+)
+
+// operationOrder is the order of the operations in the summary. The pull
+// operation covers the whole instance, i.e. the token, the manifest and all
+// the blobs.
+var operationOrder = []string{result.OperationAuth, result.OperationPull, result.FetchToken, result.FetchManifest, result.FetchBlob}
+
+// Quantiles are the quantiles reported by the summary.
+var Quantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// Phases are the names of the request phases, in the order of Operation.Phases.
+var Phases = []string{"dns", "connect", "tls", "ttfb", "transfer"}
+
+// Operation aggregates the results of an operation.
+type Operation struct {
+	Name    string
+	Count   int64
+	Success int64
+	Bytes   int64
+	Latency Histogram
+	// Requests is the number of requests sent, including the retries and
+	// redirects, of which Reused were sent over a reused connection.
+	Requests int64
+	Reused   int64
+	// Errors counts the failed operations by error category.
+	Errors map[string]int64
+	// Phases are the durations of the request phases named by Phases. The
+	// connection phases are only recorded for the requests establishing a
+	// new connection.
+	Phases [5]Histogram
+}
+
+// SuccessRate returns the fraction of successful operations.
+func (o *Operation) SuccessRate() float64 {
+	if o.Count == 0 {
+		return 0
+	}
+	return float64(o.Success) / float64(o.Count)
+}
+
+// record records the result of an operation.
+func (o *Operation) record(size int64, duration time.Duration, success bool) {
+	o.Count++
+	if success {
+		o.Success++
+	}
+	o.Bytes += size
+	o.Latency.Record(duration)
+}
+
+// recordErrors records the error counts by category.
+func (o *Operation) recordErrors(errs map[string]int) {
+	for category, n := range errs {
+		if o.Errors == nil {
+			o.Errors = make(map[string]int64)
+		}
+		o.Errors[category] += int64(n)
+	}
+}
+
+// recordRequests records the timings of the requests of an operation.
+func (o *Operation) recordRequests(timings []trace.Timing) {
+	for _, t := range timings {
+		o.Requests++
+		if t.Reused {
+			o.Reused++
+		}
+		for i, d := range []time.Duration{t.DNS, t.Connect, t.TLS} {
+			if d > 0 {
+				o.Phases[i].Record(d)
+			}
+		}
+		if t.Status != 0 {
+			o.Phases[3].Record(t.TTFB)
+			o.Phases[4].Record(t.Transfer)
+		}
+	}
+}
+
+// snapshot returns a copy of the aggregate.
+func (o *Operation) snapshot() Operation {
+	c := *o
+	c.Latency = o.Latency.clone()
+	c.Errors = maps.Clone(o.Errors)
+	for i := range o.Phases {
+		c.Phases[i] = o.Phases[i].clone()
+	}
+	return c
+}
+
+// Summary aggregates the records of a run per operation. It implements
+// result.Writer so that it can be fed along with the results output.
+type Summary struct {
+	mu         sync.Mutex
+	operations map[string]*Operation
+}
+
+// NewSummary returns an empty summary.
+func NewSummary() *Summary {
+	return &Summary{operations: make(map[string]*Operation)}
+}
+
+// Write aggregates a record and its fetches. The records of OperationFetch,
+// read from the detailed output of pulls, only aggregate their fetch.
+func (s *Summary) Write(r result.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Operation != result.OperationFetch {
+		op := s.operation(r.Operation)
+		op.record(r.Size, r.Duration, r.SuccessCount == r.TotalCount)
+		op.recordRequests(r.Requests)
+		if len(r.Fetches) == 0 {
+			// the errors of pulls are counted on their fetches
+			op.recordErrors(r.Errors)
+		}
+	}
+	for _, f := range r.Fetches {
+		op := s.operation(f.Kind)
+		op.record(f.Size, f.Duration, f.Err == nil)
+		op.recordRequests(f.Requests)
+		if f.Category != "" {
+			op.recordErrors(map[string]int{f.Category: 1})
+		}
+	}
+	return nil
+}
+
+// Close does nothing, the summary is printed by Print.
+func (s *Summary) Close() error {
+	return nil
+}
+
+// operation returns the aggregate of the named operation, creating it if needed.
+func (s *Summary) operation(name string) *Operation {
+	op, ok := s.operations[name]
+	if !ok {
+		op = &Operation{Name: name}
+		s.operations[name] = op
+	}
+	return op
+}
+
+// Operations returns the aggregates of the operations recorded so far.
+func (s *Summary) Operations() []Operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ops []Operation
+	for _, name := range operationOrder {
+		if op, ok := s.operations[name]; ok {
+			ops = append(ops, op.snapshot())
+		}
+	}
+	return ops
+}
+
+// Table is a table of a summary, printed aligned or rendered otherwise, e.g.
+// in HTML.
+type Table struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+}
+
+// Print prints the title and the aligned cells of the table.
+func (t Table) Print(w io.Writer) error {
+	fmt.Fprintln(w, t.Title)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.Header, "\t"))
+	for _, row := range t.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Print prints the summary of the run which took elapsed time.
+func (s *Summary) Print(w io.Writer, elapsed time.Duration) error {
+	for _, t := range s.Tables(elapsed) {
+		if err := t.Print(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tables returns the tables of the summary of the run which took elapsed
+// time: the latencies of the operations and, if any, the durations of the
+// request phases and the errors. It returns no table if nothing was recorded.
+func (s *Summary) Tables(elapsed time.Duration) []Table {
+	ops := s.Operations()
+	if len(ops) == 0 {
+		return nil
+	}
+	tables := []Table{operationsTable(ops, elapsed)}
+	if t := phasesTable(ops); len(t.Rows) > 0 {
+		tables = append(tables, t)
+	}
+	if t, ok := errorsTable(ops); ok {
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// operationsTable returns the count, success rate, rates and latencies of
+// the operations.
+func operationsTable(ops []Operation, elapsed time.Duration) Table {
+	t := Table{
+		Title:  fmt.Sprintf("Summary over %.2f seconds:", elapsed.Seconds()),
+		Header: []string{"operation", "count", "success", "ops/s", "MB/s", "min", "mean"},
+	}
+	for _, q := range Quantiles {
+		t.Header = append(t.Header, fmt.Sprintf("p%g", q*100))
+	}
+	t.Header = append(t.Header, "max")
+	for _, op := range ops {
+		row := []string{
+			op.Name, strconv.FormatInt(op.Count, 10), fmt.Sprintf("%.2f%%", op.SuccessRate()*100),
+			fmt.Sprintf("%.2f", perSecond(float64(op.Count), elapsed)), throughput(op, elapsed),
+			FormatDuration(op.Latency.Min()), FormatDuration(op.Latency.Mean()),
+		}
+		for _, q := range Quantiles {
+			row = append(row, FormatDuration(op.Latency.Quantile(q)))
+		}
+		t.Rows = append(t.Rows, append(row, FormatDuration(op.Latency.Max())))
+	}
+	return t
+}
+
+// errorsTable returns the breakdown of the errors of the operations by
+// category, if any.
+func errorsTable(ops []Operation) (Table, bool) {
+	totals := make(map[string]int64)
+	var total int64
+	var failedOps []Operation
+	for _, op := range ops {
+		for category, n := range op.Errors {
+			totals[category] += n
+			total += n
+		}
+		if len(op.Errors) > 0 {
+			failedOps = append(failedOps, op)
+		}
+	}
+	if total == 0 {
+		return Table{}, false
+	}
+
+	t := Table{Title: "Errors:", Header: []string{"category"}}
+	for _, op := range failedOps {
+		t.Header = append(t.Header, op.Name)
+	}
+	t.Header = append(t.Header, "total", "share")
+	for _, category := range ErrorCategories(totals) {
+		row := []string{category}
+		for _, op := range failedOps {
+			row = append(row, strconv.FormatInt(op.Errors[category], 10))
+		}
+		row = append(row, strconv.FormatInt(totals[category], 10), fmt.Sprintf("%.2f%%", float64(totals[category])/float64(total)*100))
+		t.Rows = append(t.Rows, row)
+	}
+	return t, true
+}
+
+// ErrorCategories returns the categories of the error counts in the order of
+// result.Categories, followed by the unknown categories sorted by name.
+func ErrorCategories[N int | int64](errs map[string]N) []string {
+	var categories []string
+	for _, category := range result.Categories {
+		if _, ok := errs[category]; ok {
+			categories = append(categories, category)
+		}
+	}
+	var unknown []string
+	for category := range errs {
+		if !slices.Contains(result.Categories, category) {
+			unknown = append(unknown, category)
+		}
+	}
+	slices.Sort(unknown)
+	return append(categories, unknown...)
+}
+
+// phasesTable returns the p50 and p99 durations of the request phases of the
+// operations which sent requests.
+func phasesTable(ops []Operation) Table {
+	t := Table{
+		Title:  "Request phases (p50/p99):",
+		Header: append([]string{"operation", "requests", "reused"}, Phases...),
+	}
+	for _, op := range ops {
+		if op.Requests == 0 {
+			continue
+		}
+		row := []string{op.Name, strconv.FormatInt(op.Requests, 10), fmt.Sprintf("%.2f%%", float64(op.Reused)/float64(op.Requests)*100)}
+		for _, h := range op.Phases {
+			if h.Count() == 0 {
+				row = append(row, "-")
+				continue
+			}
+			row = append(row, fmt.Sprintf("%s/%s", FormatDuration(h.Quantile(0.5)), FormatDuration(h.Quantile(0.99))))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+// throughput formats the downloaded megabytes per second of the operation.
+func throughput(op Operation, elapsed time.Duration) string {
+	if op.Name == result.OperationAuth || op.Name == result.FetchToken {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", perSecond(float64(op.Bytes)/1e6, elapsed))
+}
+
+// perSecond returns the rate of n over elapsed time.
+func perSecond(n float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return n / elapsed.Seconds()
+}
+
+// FormatDuration formats a duration with a precision relative to its magnitude.
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
@@ -0,0 +1,154 @@
+package timeseries
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/stats"
+)
+
+// header is the header of the time series.
+var header = []string{
+	"timestamp", "elapsed_seconds", "started", "completed", "active", "errors", "bytes",
+	"p50_milliseconds", "p90_milliseconds", "p99_milliseconds",
+}
+
+// Series writes the activity of a run per interval as CSV rows: the instances
+// started and completed within the interval, the instances active at its end,
+// the errors, the bytes and the latency percentiles of the completed
+// instances. It implements result.Writer so that it can be fed along with the
+// results output.
+type Series struct {
+	w        *csv.Writer
+	interval time.Duration
+	start    time.Time
+	started  atomic.Int64
+
+	mu        sync.Mutex
+	err       error
+	index     int64
+	previous  int64
+	completed int64
+	bucket    bucket
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// bucket aggregates the records completed within an interval.
+type bucket struct {
+	completed int64
+	errors    int64
+	bytes     int64
+	latency   stats.Histogram
+}
+
+// New returns a series writing to w every interval. The series is started by Start.
+func New(w io.Writer, interval time.Duration) *Series {
+	return &Series{
+		w:        csv.NewWriter(w),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start writes the header and starts writing the intervals.
+func (s *Series) Start() {
+	s.start = time.Now()
+	s.err = s.w.Write(header)
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.tick()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop writes the last interval, partial if the run ended within it, and stops
+// writing. It does nothing on a nil series, i.e. when the time series is
+// disabled.
+func (s *Series) Stop() error {
+	if s == nil {
+		return nil
+	}
+	close(s.stop)
+	<-s.done
+	s.tick()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Flush()
+	if s.err == nil {
+		s.err = s.w.Error()
+	}
+	return s.err
+}
+
+// Started counts an instance as started. It does nothing on a nil series.
+func (s *Series) Started() {
+	if s == nil {
+		return
+	}
+	s.started.Add(1)
+}
+
+// Write counts the record as completed within the current interval.
+func (s *Series) Write(r result.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completed++
+	s.bucket.completed++
+	s.bucket.bytes += r.Size
+	s.bucket.latency.Record(r.Duration)
+	for _, n := range r.Errors {
+		s.bucket.errors += int64(n)
+	}
+	return nil
+}
+
+// Close does nothing, the series is stopped by Stop.
+func (s *Series) Close() error {
+	return nil
+}
+
+// tick writes the current interval and moves to the next one.
+func (s *Series) tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	started := s.started.Load()
+	b := &s.bucket
+	timestamp := s.start.Add(time.Duration(s.index) * s.interval)
+	row := []string{
+		timestamp.Format(time.RFC3339Nano),
+		strconv.FormatFloat(timestamp.Sub(s.start).Seconds(), 'f', -1, 64),
+		strconv.FormatInt(started-s.previous, 10),
+		strconv.FormatInt(b.completed, 10),
+		strconv.FormatInt(started-s.completed, 10),
+		strconv.FormatInt(b.errors, 10),
+		strconv.FormatInt(b.bytes, 10),
+		strconv.FormatInt(b.latency.Quantile(0.5).Milliseconds(), 10),
+		strconv.FormatInt(b.latency.Quantile(0.9).Milliseconds(), 10),
+		strconv.FormatInt(b.latency.Quantile(0.99).Milliseconds(), 10),
+	}
+	if err := s.w.Write(row); err != nil && s.err == nil {
+		s.err = err
+	}
+	// flush every interval so that the series can be followed during the run
+	s.w.Flush()
+
+	s.index++
+	s.previous = started
+	s.bucket = bucket{}
+}
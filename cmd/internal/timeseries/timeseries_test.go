@@ -0,0 +1,55 @@
+package timeseries
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+)
+
+func TestSeries(t *testing.T) {
+	var buf bytes.Buffer
+	s := New(&buf, time.Hour)
+	s.Start()
+	for range 3 {
+		s.Started()
+	}
+	s.Write(result.Record{Operation: result.OperationPull, Duration: time.Second, Size: 1000, Errors: map[string]int{"http_429": 2}})
+	s.Write(result.Record{Operation: result.OperationPull, Duration: time.Second, Size: 500})
+	s.tick()
+	s.Started()
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read series: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want the header and 2 intervals", len(rows))
+	}
+	if !slices.Equal(rows[0], header) {
+		t.Errorf("header = %v, want %v", rows[0], header)
+	}
+	// timestamp is skipped
+	want := [][]string{
+		{"0", "3", "2", "1", "2", "1500", "1000", "1000", "1000"},
+		{"3600", "1", "0", "2", "0", "0", "0", "0", "0"},
+	}
+	for i, row := range rows[1:] {
+		if got := row[1:]; !slices.Equal(got, want[i]) {
+			t.Errorf("interval %d = %v, want %v", i, got, want[i])
+		}
+	}
+
+	// a nil series is disabled
+	var disabled *Series
+	disabled.Started()
+	if err := disabled.Stop(); err != nil {
+		t.Errorf("Stop() on nil series error = %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package option
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/stats"
+	"github.com/spf13/pflag"
+)
+
+// Assertions represents the thresholds the metrics of a run are asserted against.
+type Assertions struct {
+	Assertions []stats.Assertion
+
+	exprs []string
+}
+
+// ApplyFlags applies the flags to the assertion options.
+func (a *Assertions) ApplyFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&a.exprs, "assert", nil, "Assert a metric of the run in the format [<operation>.]<metric><comparator><value>, e.g. 'blob.p99<2s' or 'error_rate<0.5%', exiting with a non-zero code if violated")
+}
+
+// Parse parses the assertions.
+func (a *Assertions) Parse() error {
+	a.Assertions = nil
+	for _, expr := range a.exprs {
+		assertion, err := stats.ParseAssertion(expr)
+		if err != nil {
+			return fmt.Errorf("Error parsing assert option: %v\n", err)
+		}
+		a.Assertions = append(a.Assertions, assertion)
+	}
+	return nil
+}
+
+// Check checks the assertions against the operations of a run which took
+// elapsed time, operation being the operation of its instances. The results
+// of the assertions are printed to stderr.
+func (a *Assertions) Check(ops []stats.Operation, elapsed time.Duration, operation string) error {
+	if len(a.Assertions) == 0 {
+		return nil
+	}
+	t := stats.Table{Title: "Assertions:", Header: []string{"assertion", "actual", "result"}}
+	var violated int
+	for _, assertion := range a.Assertions {
+		actual, ok := assertion.Check(ops, elapsed, operation)
+		result := "passed"
+		if !ok {
+			result = "VIOLATED"
+			violated++
+		}
+		t.Rows = append(t.Rows, []string{assertion.Expr, actual, result})
+	}
+	if err := t.Print(os.Stderr); err != nil {
+		return err
+	}
+	if violated > 0 {
+		return fmt.Errorf("%d of %d assertions violated\n", violated, len(a.Assertions))
+	}
+	return nil
+}
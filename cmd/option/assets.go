@@ -0,0 +1,51 @@
+package option
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/selector"
+	"github.com/spf13/pflag"
+)
+
+// defaultAssetsDir is the asset directory used when present in the working directory.
+const defaultAssetsDir = "assets/images"
+
+// Assets represents the options related to the image descriptions to pull.
+type Assets struct {
+	Images   []asset.Asset
+	Selector selector.Selector
+
+	source       string
+	distribution string
+}
+
+// ApplyFlags applies the flags to the assets options.
+func (a *Assets) ApplyFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&a.distribution, "distribution", "uniform", "Distribution of the picked images: uniform, zipf[=<exponent>] ranking the images by their rank field or in a seeded random order, weight[=<field>] or size")
+	flags.StringVar(&a.source, "assets", "", "Image descriptions to pull: a directory of JSON files, a JSON-lines catalog file, or \"embedded\" (default: assets/images if present, otherwise embedded)")
+}
+
+// Parse loads and validates all the image descriptions of the source, and sets
+// up the selector picking the images to pull, seeded like the picks.
+func (a *Assets) Parse(seed int64) error {
+	source := a.source
+	if source == "" {
+		source = asset.Embedded
+		if info, err := os.Stat(defaultAssetsDir); err == nil && info.IsDir() {
+			source = defaultAssetsDir
+		}
+	}
+	s, err := asset.NewSource(source)
+	if err != nil {
+		return fmt.Errorf("Error opening assets %q: %v\n", source, err)
+	}
+	if a.Images, err = s.Load(); err != nil {
+		return fmt.Errorf("Error loading assets %q: %v\n", source, err)
+	}
+	if a.Selector, err = selector.New(a.distribution, a.Images, seed); err != nil {
+		return fmt.Errorf("Error parsing distribution %q: %v\n", a.distribution, err)
+	}
+	return nil
+}
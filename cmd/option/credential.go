@@ -0,0 +1,70 @@
+package option
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/billy-playground/registry-load-tester/internal/auth"
+	"github.com/spf13/pflag"
+)
+
+// defaultClientID identifies the tool in the OAuth2 token flows.
+const defaultClientID = "registry-load-tester"
+
+// Credential represents the credential and the flow tokens are requested with.
+type Credential struct {
+	auth.Credential
+	Flow     string
+	ClientID string
+}
+
+// ApplyFlags applies the flags to the credential options.
+func (c *Credential) ApplyFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&c.Flow, "flow", auth.FlowBearer, "Flow of the token requests: "+strings.Join(auth.Flows, ", "))
+	flags.StringVarP(&c.Username, "username", "u", "", "Username of the basic and password flows, or of registries only supporting basic authentication")
+	flags.StringVarP(&c.Password, "password", "p", "", "Password of the basic and password flows, or of registries only supporting basic authentication")
+	flags.StringVarP(&c.RefreshToken, "refresh-token", "r", "", "Token used for refreshing")
+	c.ApplyClientIDFlag(flags)
+}
+
+// ApplyClientIDFlag applies the client ID flag for the commands selecting the
+// flow otherwise, e.g. from the token mode.
+func (c *Credential) ApplyClientIDFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&c.ClientID, "client-id", defaultClientID, "Client ID of the refresh_token and password flows")
+}
+
+// Parse checks the credential required by the flow.
+func (c *Credential) Parse() error {
+	switch {
+	case !slices.Contains(auth.Flows, c.Flow):
+		return fmt.Errorf("Unknown token flow %q, expecting %s\n", c.Flow, strings.Join(auth.Flows, ", "))
+	case (c.Flow == auth.FlowBasic || c.Flow == auth.FlowPassword) && c.Username == "":
+		return fmt.Errorf("The %s flow requires a username\n", c.Flow)
+	case c.Flow == auth.FlowRefreshToken && c.RefreshToken == "":
+		return fmt.Errorf("The %s flow requires a refresh token\n", c.Flow)
+	}
+	return nil
+}
+
+// TokenRequest returns the request of a token of the scopes to the token
+// service of the challenge.
+func (c *Credential) TokenRequest(challenge auth.Challenge, scopes ...string) auth.TokenRequest {
+	return auth.TokenRequest{
+		Flow:       c.Flow,
+		Realm:      challenge.Realm,
+		Service:    challenge.Service,
+		Scopes:     scopes,
+		ClientID:   c.ClientID,
+		Credential: c.Credential,
+	}
+}
+
+// BasicOnly checks that the credential can authenticate with a registry only
+// supporting basic authentication.
+func (c *Credential) BasicOnly() error {
+	if c.Username == "" {
+		return fmt.Errorf("The registry only supports basic authentication, which requires a username\n")
+	}
+	return nil
+}
@@ -3,14 +3,34 @@ package option
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/schedule"
 )
 
+// Instance represents the number of instances of a run and when they start.
+// The instance option is in the format <num_instances>[=<arrival>], where
+// arrival can be one of the following:
+//
+//	<size>/<interval>: start size instances every interval
+//	rate=<n>/<unit>: start n instances per unit at a constant rate, e.g. rate=50/s
+//	poisson=<n>/<unit>: start n instances per unit on average as a Poisson process
+//	ramp=<from>,<to>/<unit>:<duration>: increase the rate linearly over the duration, e.g. ramp=10,100/s:5m
+//	steps=<n1>,<n2>,.../<unit>:<hold>: hold each rate for the hold time, e.g. steps=10,20,40/s:1m
+//	spike=<base>,<peak>/<unit>:<base_hold>,<spike_hold>,<recover_hold>: hold the base rate,
+//	    spike to the peak rate and recover to the base rate, e.g. spike=10,200/s:1m,10s,2m
+//
+// Rate profiles (ramp, steps and spike) end with their last phase, even if
+// fewer than num_instances instances have been started.
 type Instance struct {
 	Count         int
 	BatchSize     int
 	BatchInterval time.Duration
+	Arrival       schedule.Arrival
 
 	flag string
 }
@@ -24,6 +44,7 @@ func (i *Instance) Parse() error {
 	var count int
 	var size int
 	var interval time.Duration
+	var arrival schedule.Arrival
 
 	numInstancesOption, frequencyOption, ok := strings.Cut(i.flag, "=")
 	if _, err := fmt.Sscanf(numInstancesOption, "%d", &count); err != nil {
@@ -33,7 +54,14 @@ func (i *Instance) Parse() error {
 		return fmt.Errorf("Number of instances must be greater than 0\n")
 	}
 
-	if ok {
+	switch model, rateOption, isRate := strings.Cut(frequencyOption, "="); {
+	case !ok:
+		arrival = schedule.Burst{}
+	case isRate:
+		if arrival, err = parseArrivalModel(model, rateOption); err != nil {
+			return err
+		}
+	default:
 		sizeOption, intervalOption, ok := strings.Cut(frequencyOption, "/")
 		if !ok {
 			return errors.New("Batch size and interval should be in the format <size>/<interval>\n")
@@ -50,10 +78,134 @@ func (i *Instance) Parse() error {
 		if interval <= 0 {
 			return fmt.Errorf("Interval must be greater than 0\n")
 		}
+		arrival = schedule.Burst{Size: size, Interval: interval}
 	}
 	i.Count = count
 	i.BatchSize = size
 	i.BatchInterval = interval
+	i.Arrival = arrival
 
 	return nil
 }
+
+// parseArrivalModel parses the rate-based arrival models in the format <model>=<spec>.
+func parseArrivalModel(model string, spec string) (schedule.Arrival, error) {
+	switch model {
+	case "rate", "poisson":
+		rates, err := parseRates(spec, 1)
+		if err != nil {
+			return nil, err
+		}
+		if model == "rate" {
+			return schedule.Constant{Rate: rates[0]}, nil
+		}
+		return schedule.Poisson{Rate: rates[0]}, nil
+	case "ramp", "steps", "spike":
+		rateOption, durationOption, ok := strings.Cut(spec, ":")
+		if !ok {
+			return nil, fmt.Errorf("Rate profile %s should be in the format <rates>/<unit>:<durations>\n", model)
+		}
+		durations, err := parseDurations(durationOption)
+		if err != nil {
+			return nil, err
+		}
+		switch model {
+		case "ramp":
+			rates, err := parseRates(rateOption, 2)
+			if err != nil {
+				return nil, err
+			}
+			if len(durations) != 1 {
+				return nil, fmt.Errorf("Ramp takes a single duration\n")
+			}
+			return schedule.Profile{Segments: []schedule.Segment{
+				{Duration: durations[0], From: rates[0], To: rates[1]},
+			}}, nil
+		case "steps":
+			rates, err := parseRates(rateOption, 0)
+			if err != nil {
+				return nil, err
+			}
+			if len(durations) != 1 {
+				return nil, fmt.Errorf("Steps take a single hold time\n")
+			}
+			var profile schedule.Profile
+			for _, rate := range rates {
+				profile.Segments = append(profile.Segments, schedule.Segment{Duration: durations[0], From: rate, To: rate})
+			}
+			return profile, nil
+		default:
+			rates, err := parseRates(rateOption, 2)
+			if err != nil {
+				return nil, err
+			}
+			if len(durations) != 3 {
+				return nil, fmt.Errorf("Spike takes the base, spike and recover hold times\n")
+			}
+			base, peak := rates[0], rates[1]
+			return schedule.Profile{Segments: []schedule.Segment{
+				{Duration: durations[0], From: base, To: base},
+				{Duration: durations[1], From: peak, To: peak},
+				{Duration: durations[2], From: base, To: base},
+			}}, nil
+		}
+	default:
+		return nil, fmt.Errorf("Unknown arrival model %q, expecting rate, poisson, ramp, steps or spike\n", model)
+	}
+}
+
+// parseRates parses comma-separated rates sharing a unit in the format
+// <n1>,<n2>,.../<unit> and returns them per second. The unit is a duration,
+// where a missing number means 1, e.g. 50/s or 3/10s. If count is positive,
+// exactly count rates are expected.
+func parseRates(input string, count int) ([]float64, error) {
+	countsOption, unitOption, ok := strings.Cut(input, "/")
+	if !ok {
+		return nil, errors.New("Rate should be in the format <n>/<unit>\n")
+	}
+	if unitOption != "" && !unicode.IsDigit(rune(unitOption[0])) {
+		unitOption = "1" + unitOption
+	}
+	unit, err := time.ParseDuration(unitOption)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing rate unit from %q: %v\n", unitOption, err)
+	}
+	if unit <= 0 {
+		return nil, fmt.Errorf("Rate unit must be greater than 0\n")
+	}
+	countOptions := strings.Split(countsOption, ",")
+	if count > 0 && len(countOptions) != count {
+		return nil, fmt.Errorf("Expecting %d rates, got %q\n", count, countsOption)
+	}
+	rates := make([]float64, len(countOptions))
+	for i, countOption := range countOptions {
+		n, err := strconv.ParseFloat(countOption, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing rate from %q: %v\n", countOption, err)
+		}
+		if n < 0 || math.IsInf(n, 1) || math.IsNaN(n) {
+			return nil, fmt.Errorf("Rate must be a finite number not less than 0\n")
+		}
+		rates[i] = n / unit.Seconds()
+	}
+	if count == 1 && rates[0] == 0 {
+		return nil, fmt.Errorf("Rate must be greater than 0\n")
+	}
+	return rates, nil
+}
+
+// parseDurations parses comma-separated positive durations.
+func parseDurations(input string) ([]time.Duration, error) {
+	var durations []time.Duration
+	for _, option := range strings.Split(input, ",") {
+		d, err := time.ParseDuration(option)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing duration from %q: %v\n", option, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("Duration must be greater than 0\n")
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
@@ -1,8 +1,11 @@
 package option
 
 import (
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/schedule"
 )
 
 func TestParseInstanceOption(t *testing.T) {
@@ -19,6 +22,7 @@ func TestParseInstanceOption(t *testing.T) {
 				Count:         10,
 				BatchSize:     5,
 				BatchInterval: 2 * time.Second,
+				Arrival:       schedule.Burst{Size: 5, Interval: 2 * time.Second},
 			},
 			wantErr: false,
 		},
@@ -37,10 +41,120 @@ func TestParseInstanceOption(t *testing.T) {
 			name:  "Valid input: no batch size and interval",
 			input: "10",
 			want: Instance{
-				Count: 10,
+				Count:   10,
+				Arrival: schedule.Burst{},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Valid input: constant rate",
+			input: "100=rate=50/s",
+			want: Instance{
+				Count:   100,
+				Arrival: schedule.Constant{Rate: 50},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Valid input: constant rate with multiplied unit",
+			input: "100=rate=3/500ms",
+			want: Instance{
+				Count:   100,
+				Arrival: schedule.Constant{Rate: 6},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Valid input: fractional Poisson rate per minute",
+			input: "100=poisson=1.5/m",
+			want: Instance{
+				Count:   100,
+				Arrival: schedule.Poisson{Rate: 0.025},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Valid input: linear ramp",
+			input: "1000=ramp=10,100/s:5m",
+			want: Instance{
+				Count: 1000,
+				Arrival: schedule.Profile{Segments: []schedule.Segment{
+					{Duration: 5 * time.Minute, From: 10, To: 100},
+				}},
 			},
 			wantErr: false,
 		},
+		{
+			name:  "Valid input: steps",
+			input: "1000=steps=10,20,40/s:1m",
+			want: Instance{
+				Count: 1000,
+				Arrival: schedule.Profile{Segments: []schedule.Segment{
+					{Duration: time.Minute, From: 10, To: 10},
+					{Duration: time.Minute, From: 20, To: 20},
+					{Duration: time.Minute, From: 40, To: 40},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "Valid input: spike",
+			input: "1000=spike=10,200/s:1m,10s,2m",
+			want: Instance{
+				Count: 1000,
+				Arrival: schedule.Profile{Segments: []schedule.Segment{
+					{Duration: time.Minute, From: 10, To: 10},
+					{Duration: 10 * time.Second, From: 200, To: 200},
+					{Duration: 2 * time.Minute, From: 10, To: 10},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Invalid input: ramp without duration",
+			input:   "1000=ramp=10,100/s",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid input: ramp with a single rate",
+			input:   "1000=ramp=10/s:5m",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid input: spike with missing hold times",
+			input:   "1000=spike=10,200/s:1m,10s",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid input: steps with negative rate",
+			input:   "1000=steps=10,-20/s:1m",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid input: unknown arrival model",
+			input:   "100=burst=50/s",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid input: missing rate unit",
+			input:   "100=rate=50",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid input: invalid rate unit",
+			input:   "100=rate=50/x",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid input: rate is zero",
+			input:   "100=poisson=0/s",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid input: rate is infinite",
+			input:   "100=rate=inf/s",
+			wantErr: true,
+		},
 		{
 			name:    "Invalid input: missing batch size and interval",
 			input:   "10=",
@@ -107,6 +221,9 @@ func TestParseInstanceOption(t *testing.T) {
 				if instance.BatchInterval != tt.want.BatchInterval {
 					t.Errorf("Parse() BatchInterval = %v, want %v", instance.BatchInterval, tt.want.BatchInterval)
 				}
+				if tt.want.Arrival != nil && !reflect.DeepEqual(instance.Arrival, tt.want.Arrival) {
+					t.Errorf("Parse() Arrival = %v, want %v", instance.Arrival, tt.want.Arrival)
+				}
 			}
 		})
 	}
@@ -0,0 +1,25 @@
+package option
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// Limits represents the bounds of the execution engine.
+type Limits struct {
+	MaxInstances int
+}
+
+// ApplyFlags applies the flags to the limits options.
+func (l *Limits) ApplyFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&l.MaxInstances, "max-instances", 0, "Maximum number of concurrently active instances, 0 for no limit. A limit, e.g. 1000, is recommended for large runs not to exhaust the resources of the load generator, such as file descriptors")
+}
+
+// Parse validates the limits options.
+func (l *Limits) Parse() error {
+	if l.MaxInstances < 0 {
+		return fmt.Errorf("Maximum number of instances must not be negative\n")
+	}
+	return nil
+}
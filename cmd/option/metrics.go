@@ -0,0 +1,67 @@
+package option
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/metrics"
+	"github.com/spf13/pflag"
+)
+
+// Metrics represents the Prometheus metrics endpoint of a run.
+type Metrics struct {
+	Exporter *metrics.Exporter
+
+	addr   string
+	linger time.Duration
+	server *http.Server
+}
+
+// ApplyFlags applies the flags to the metrics options.
+func (m *Metrics) ApplyFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&m.addr, "metrics-addr", "", "Address to serve the Prometheus metrics of the run on /metrics, e.g. :9090 (default: disabled)")
+	flags.DurationVar(&m.linger, "metrics-linger", 0, "Time to keep serving the metrics after the run, e.g. 30s for the final values to be scraped")
+}
+
+// Parse starts serving the metrics of the run against the registry, if an
+// address is specified.
+func (m *Metrics) Parse(registry string) error {
+	if m.addr == "" {
+		return nil
+	}
+	if m.linger < 0 {
+		return fmt.Errorf("Metrics linger must not be negative\n")
+	}
+	listener, err := net.Listen("tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("Error listening on metrics address: %v\n", err)
+	}
+	m.Exporter = metrics.New(registry)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Exporter.Handler())
+	m.server = &http.Server{Handler: mux}
+	go m.server.Serve(listener)
+	fmt.Fprintf(os.Stderr, "Serving metrics on http://%s/metrics\n", listener.Addr())
+	return nil
+}
+
+// Close stops serving the metrics once the linger elapses, or ctx is done.
+func (m *Metrics) Close(ctx context.Context) error {
+	if m.server == nil {
+		return nil
+	}
+	if m.linger > 0 && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "Serving the final metrics for %v\n", m.linger)
+		timer := time.NewTimer(m.linger)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	return m.server.Shutdown(context.Background())
+}
@@ -0,0 +1,71 @@
+package option
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/spf13/pflag"
+)
+
+// Output represents where and how the results of a run are written.
+type Output struct {
+	Results result.Writer
+
+	format   string
+	file     string
+	detailed bool
+	closer   io.Closer
+}
+
+// ApplyFlags applies the flags to the output options.
+func (o *Output) ApplyFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.format, "output-format", result.FormatCSV, "Format of the results: "+strings.Join(result.Formats, ", "))
+	flags.StringVarP(&o.file, "output", "o", "", "File to write the results to (default: stdout)")
+}
+
+// ApplyDetailedFlag applies the detailed output flag for the commands pulling images.
+func (o *Output) ApplyDetailedFlag(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.detailed, "detailed", false, "Write a result for each manifest and blob fetch instead of each pulled image")
+}
+
+// Parse creates the writer of the results of the operation.
+func (o *Output) Parse(operation string) error {
+	if o.detailed && operation == result.OperationPull {
+		operation = result.OperationFetch
+	}
+	var w io.Writer = os.Stdout
+	if o.file != "" {
+		f, err := os.Create(o.file)
+		if err != nil {
+			return fmt.Errorf("Error creating output file: %v\n", err)
+		}
+		w, o.closer = f, f
+	}
+	results, err := result.NewWriter(w, o.format, operation)
+	if err != nil {
+		o.Close()
+		return fmt.Errorf("Error parsing output option: %v\n", err)
+	}
+	o.Results = results
+	return nil
+}
+
+// Close flushes the results and closes the output file.
+func (o *Output) Close() error {
+	var err error
+	if o.Results != nil {
+		err = o.Results.Close()
+	}
+	if o.closer != nil {
+		if cerr := o.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Error writing results: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package option
+
+import (
+	"os"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/progress"
+	"github.com/spf13/pflag"
+)
+
+// Progress represents the live progress display of a run.
+type Progress struct {
+	enabled bool
+}
+
+// ApplyFlags applies the flags to the progress options.
+func (p *Progress) ApplyFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&p.enabled, "progress", true, "Show the live progress of the run on stderr, only when stderr is a terminal")
+}
+
+// Start starts the live progress display refreshed every second. It returns
+// nil if the display is disabled or stderr is not a terminal.
+func (p *Progress) Start() *progress.Display {
+	if !p.enabled || !isTerminal(os.Stderr) {
+		return nil
+	}
+	display := progress.New(os.Stderr, time.Second)
+	display.Start()
+	return display
+}
+
+// isTerminal returns whether the file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
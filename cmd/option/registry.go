@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/billy-playground/registry-load-tester/internal/trace"
 	"github.com/spf13/pflag"
 )
 
@@ -25,10 +26,12 @@ func (r *Registry) ApplyFlags(flags *pflag.FlagSet) {
 	flags.StringVarP(&r.registryEndpoint, "registry-endpoint", "e", "", "Endpoint of the registry domain (default: registryDomain)")
 }
 
-// Parse parses the registry options and sets up the HTTP client.
+// Parse parses the registry options and sets up the HTTP client, tracing the
+// timings of the requests.
 func (r *Registry) Parse() error {
+	transport := http.DefaultTransport
 	if r.registryEndpoint != "" {
-		http.DefaultClient.Transport = &http.Transport{
+		transport = &http.Transport{
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 				if after, found := strings.CutPrefix(addr, r.RegistryDomain); found {
 					// Resolve registry to endpoint
@@ -38,5 +41,6 @@ func (r *Registry) Parse() error {
 			},
 		}
 	}
+	http.DefaultClient.Transport = &trace.Transport{Base: transport}
 	return nil
 }
@@ -0,0 +1,51 @@
+package option
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/internal/retry"
+	"github.com/spf13/pflag"
+)
+
+// Retry represents the retry policy of the requests sent to the registry.
+type Retry struct {
+	retry.Policy
+
+	retryableStatus []int
+}
+
+// ApplyFlags applies the flags to the retry options.
+func (r *Retry) ApplyFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&r.MaxAttempts, "max-attempts", 1, "Maximum number of attempts of each request, including the first one, 1 for no retry")
+	flags.DurationVar(&r.InitialBackoff, "retry-backoff", 500*time.Millisecond, "Backoff before the first retry, doubled after each retry with a random jitter")
+	flags.DurationVar(&r.MaxBackoff, "retry-max-backoff", 10*time.Second, "Maximum backoff between retries, including the delays of the Retry-After headers, 0 for no limit")
+	flags.IntSliceVar(&r.retryableStatus, "retry-status", retry.DefaultRetryableStatus, "Response status codes which are retried")
+	flags.BoolVar(&r.HonorRetryAfter, "retry-after", true, "Wait for the delay of the Retry-After header of retried responses instead of the backoff")
+}
+
+// Parse validates the retry options and sets up the HTTP client to retry
+// requests. It must be called after the HTTP client transport is set up.
+func (r *Retry) Parse() error {
+	if r.MaxAttempts < 1 {
+		return fmt.Errorf("Maximum number of attempts must be greater than 0\n")
+	}
+	if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+		return fmt.Errorf("Retry backoff must not be negative\n")
+	}
+	r.RetryableStatus = make(map[int]bool, len(r.retryableStatus))
+	for _, status := range r.retryableStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("Invalid retryable status code %d\n", status)
+		}
+		r.RetryableStatus[status] = true
+	}
+	if r.MaxAttempts > 1 {
+		http.DefaultClient.Transport = &retry.Transport{
+			Base:   http.DefaultClient.Transport,
+			Policy: r.Policy,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package option
+
+import (
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Seed represents the seed of the random choices made by a run.
+type Seed struct {
+	Seed int64
+
+	flag *pflag.Flag
+}
+
+// ApplyFlags applies the flags to the seed options.
+func (s *Seed) ApplyFlags(flags *pflag.FlagSet) {
+	flags.Int64Var(&s.Seed, "seed", 0, "Seed of the random image picks for reproducible runs (default: random)")
+	s.flag = flags.Lookup("seed")
+}
+
+// Parse picks a random seed if none is specified, 0 being a valid seed.
+func (s *Seed) Parse() error {
+	if s.flag == nil || !s.flag.Changed {
+		s.Seed = time.Now().UnixNano()
+	}
+	return nil
+}
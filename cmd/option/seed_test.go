@@ -0,0 +1,42 @@
+package option
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestSeedParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		random bool
+		want   int64
+	}{
+		{name: "Unset", args: nil, random: true},
+		{name: "Zero", args: []string{"--seed", "0"}, want: 0},
+		{name: "Set", args: []string{"--seed", "42"}, want: 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Seed
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			s.ApplyFlags(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Parse(); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if tt.random {
+				if s.Seed == 0 {
+					t.Error("Parse() kept the seed 0, want a random seed")
+				}
+				return
+			}
+			if s.Seed != tt.want {
+				t.Errorf("Parse() seed = %d, want %d", s.Seed, tt.want)
+			}
+		})
+	}
+}
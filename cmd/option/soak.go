@@ -0,0 +1,43 @@
+package option
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Soak represents the options of a soak run, which keeps a fixed number of
+// instances active until a deadline instead of starting a fixed number of them.
+type Soak struct {
+	Duration    time.Duration
+	Concurrency int
+}
+
+// ApplyFlags applies the flags to the soak options.
+func (s *Soak) ApplyFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&s.Duration, "duration", 0, "Run in soak mode for the duration, starting a new instance as each one finishes, instead of starting <num_instances>")
+	flags.IntVar(&s.Concurrency, "concurrency", 0, "Number of instances kept active in soak mode")
+}
+
+// Enabled returns whether the soak mode is enabled.
+func (s *Soak) Enabled() bool {
+	return s.Duration > 0
+}
+
+// Parse validates the soak options.
+func (s *Soak) Parse() error {
+	if s.Duration < 0 {
+		return fmt.Errorf("Duration must be greater than 0\n")
+	}
+	if s.Concurrency < 0 {
+		return fmt.Errorf("Concurrency must be greater than 0\n")
+	}
+	if s.Enabled() && s.Concurrency == 0 {
+		return fmt.Errorf("Concurrency must be set in soak mode\n")
+	}
+	if !s.Enabled() && s.Concurrency > 0 {
+		return fmt.Errorf("Concurrency requires a soak duration\n")
+	}
+	return nil
+}
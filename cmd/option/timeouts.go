@@ -0,0 +1,32 @@
+package option
+
+import (
+	"fmt"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/runner"
+	"github.com/spf13/pflag"
+)
+
+// Timeouts represents the timeouts of the test instances.
+type Timeouts struct {
+	runner.Timeouts
+}
+
+// ApplyFlags applies the flags to the timeouts options.
+func (t *Timeouts) ApplyFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&t.Request, "request-timeout", 0, "Timeout of each request, including its retries and reading the response body, 0 for no timeout")
+	flags.DurationVar(&t.Instance, "instance-timeout", 0, "Timeout of each instance as a whole, 0 for no timeout")
+}
+
+// ApplyStallFlag applies the stall detection flag for the commands downloading content.
+func (t *Timeouts) ApplyStallFlag(flags *pflag.FlagSet) {
+	flags.DurationVar(&t.Stall, "stall-timeout", 0, "Abort a request when no bytes are received for the duration, 0 for no stall detection")
+}
+
+// Parse validates the timeouts options.
+func (t *Timeouts) Parse() error {
+	if t.Request < 0 || t.Instance < 0 || t.Stall < 0 {
+		return fmt.Errorf("Timeouts must not be negative\n")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package option
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/timeseries"
+	"github.com/spf13/pflag"
+)
+
+// TimeSeries represents the time series output of a run.
+type TimeSeries struct {
+	Series *timeseries.Series
+
+	file     string
+	interval time.Duration
+	closer   *os.File
+}
+
+// ApplyFlags applies the flags to the time series options.
+func (t *TimeSeries) ApplyFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&t.file, "timeseries", "", "File to write the activity of the run per interval to as CSV (default: disabled)")
+	flags.DurationVar(&t.interval, "timeseries-interval", time.Second, "Interval of the time series")
+}
+
+// Parse creates the time series, if a file is specified. The series is
+// started along with the run.
+func (t *TimeSeries) Parse() error {
+	if t.file == "" {
+		return nil
+	}
+	if t.interval <= 0 {
+		return fmt.Errorf("Time series interval must be greater than 0\n")
+	}
+	f, err := os.Create(t.file)
+	if err != nil {
+		return fmt.Errorf("Error creating timeseries file: %v\n", err)
+	}
+	t.Series = timeseries.New(f, t.interval)
+	t.closer = f
+	return nil
+}
+
+// Close closes the time series file.
+func (t *TimeSeries) Close() error {
+	if t.closer == nil {
+		return nil
+	}
+	if err := t.closer.Close(); err != nil {
+		return fmt.Errorf("Error writing timeseries: %v\n", err)
+	}
+	return nil
+}
@@ -1,16 +1,29 @@
 package option
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/billy-playground/registry-load-tester/internal/auth"
+	"github.com/spf13/pflag"
 )
 
 // Token represents the token option for the registry load tester.
 type Token struct {
 	tokenModeInput string
-	AccessToken    string
+	Credential     Credential
+	// Authorization is the value of the Authorization header of the registry
+	// requests, empty to request tokens with TokenRequest or follow the
+	// authentication challenges instead.
+	Authorization string
+	// TokenRequest requests the tokens of the repositories pulled by the
+	// instances, nil if the tokens are not requested by the tool.
+	TokenRequest *auth.TokenRequest
+	// TokenCache shares the tokens between the instances, nil to request a
+	// token for each instance.
+	TokenCache *auth.TokenCache
+	cache      bool
 }
 
 // SetFlag sets the token mode for the token option.
@@ -18,41 +31,91 @@ func (t *Token) SetFlag(tokenMode string) {
 	t.tokenModeInput = tokenMode
 }
 
+// ApplyFlags applies the flags to the token options.
+func (t *Token) ApplyFlags(flags *pflag.FlagSet) {
+	t.Credential.ApplyClientIDFlag(flags)
+	flags.BoolVar(&t.cache, "token-cache", false, "Share the token of each repository between the instances instead of requesting one per instance")
+}
+
 // Parse retrieves the appropriate token based on the token mode.
 // The token option can be one of the following:
 //
 //	none: request without token and follow oauth2
-//	anonymous: get anonymous access token once and share between all instances
-//	token=<token>: use provided token
-func (t *Token) Parse(registry string) (err error) {
+//	anonymous: get anonymous access tokens
+//	token=<token>: exchange the provided refresh token, sent as a bearer token
+//	refresh_token=<token>: exchange the provided refresh token with the OAuth2 refresh_token grant
+//	password=<username>:<password>: get a token with the OAuth2 password grant
+//	basic=<username>:<password>: get a token with basic auth, or use basic auth
+//	  with registries only supporting basic authentication
+//
+// Each instance requests a token of the scope of the repository it pulls,
+// shared between the instances with the token cache. The registry and its
+// token service are requested with ctx.
+func (t *Token) Parse(ctx context.Context, registry string) (err error) {
+	mode, value, _ := strings.Cut(t.tokenModeInput, "=")
+	t.Credential.Credential = auth.Credential{}
 	switch {
 	case t.tokenModeInput == "none":
 		return nil
 	case t.tokenModeInput == "anonymous":
-		t.AccessToken, err = getAuthToken(registry, "")
-		return err
-	case strings.HasPrefix(t.tokenModeInput, "token="):
-		t.AccessToken, err = getAuthToken(registry, strings.TrimPrefix(t.tokenModeInput, "token="))
-		return err
+		t.Credential.Flow = auth.FlowBearer
+	case mode == "token":
+		t.Credential.Flow = auth.FlowBearer
+		t.Credential.RefreshToken = value
+	case mode == auth.FlowRefreshToken:
+		t.Credential.Flow = auth.FlowRefreshToken
+		t.Credential.RefreshToken = value
+	case mode == auth.FlowPassword, mode == auth.FlowBasic:
+		username, password, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("invalid token option: %s, expecting %s=<username>:<password>", mode, mode)
+		}
+		t.Credential.Flow = mode
+		t.Credential.Username, t.Credential.Password = username, password
 	default:
 		return fmt.Errorf("invalid token option: %s", t.tokenModeInput)
 	}
-}
+	if err := t.Credential.Parse(); err != nil {
+		return err
+	}
 
-var getAuthToken = func(registry string, refreshToken string) (string, error) {
-	authHeader, err := auth.GetAuthHeader(registry)
+	authHeader, err := getAuthHeader(ctx, registry)
 	if err != nil {
-		return "", err
+		return err
 	}
-
 	if authHeader == "" {
-		// no access token obtained since the registry requires no authN at all
-		return "", nil
+		// no token needed since the registry requires no authN at all
+		return nil
 	}
-	realm, service, err := auth.ParseRealmAndService(authHeader)
+	challenge, err := auth.ParseChallenge(authHeader)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if challenge.Scheme == "basic" {
+		if err := t.Credential.BasicOnly(); err != nil {
+			return err
+		}
+		t.Authorization = auth.BasicAuthorization(t.Credential.Credential)
+		return nil
+	}
+	// the scopes are set by the instances, a token without scope checks the
+	// credential before any instance starts
+	request := t.Credential.TokenRequest(challenge)
+	if err := checkToken(ctx, request); err != nil {
+		return err
+	}
+	t.TokenRequest = &request
+	if t.cache {
+		t.TokenCache = auth.NewTokenCache()
 	}
+	return nil
+}
+
+var getAuthHeader = func(ctx context.Context, registry string) (string, error) {
+	return auth.GetAuthHeader(ctx, registry)
+}
 
-	return auth.ExchangeToken(realm, service, refreshToken)
+var checkToken = func(ctx context.Context, request auth.TokenRequest) error {
+	_, err := auth.FetchToken(ctx, request)
+	return err
 }
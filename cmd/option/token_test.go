@@ -1,31 +1,47 @@
 package option
 
 import (
+	"context"
 	"errors"
 	"testing"
+
+	"github.com/billy-playground/registry-load-tester/internal/auth"
 )
 
 const (
 	mocked_anonymous_registry = "anonymous_registry"
 	mocked_auth_registry      = "auth_registry"
+	mocked_basic_registry     = "basic_registry"
 	mocked_invalid_registry   = "invalid_registry"
 	mocked_identity_token     = "mocked_identity_token"
-	mocked_registry_token     = "mocked_registry_token"
 	mocked_invalid_token      = "invalid_token"
+	mocked_realm              = "https://auth.example.com/token"
 )
 
 func TestParseTokenOption(t *testing.T) {
-	// Mocking the getAuthToken function
-	getAuthToken = func(registry string, identity_token string) (string, error) {
+	// Mocking the challenges of the registries and the token service
+	getAuthHeader = func(_ context.Context, registry string) (string, error) {
+		switch registry {
+		case mocked_anonymous_registry, mocked_auth_registry:
+			return `Bearer realm="` + mocked_realm + `",service="` + registry + `"`, nil
+		case mocked_basic_registry:
+			return `Basic realm="registry"`, nil
+		}
+		return "", errors.New("invalid registry")
+	}
+	checkToken = func(_ context.Context, request auth.TokenRequest) error {
 		switch {
-		case registry == mocked_anonymous_registry:
-			// mock returning an anonymous registry token
-			return mocked_identity_token, nil
-		case registry == mocked_auth_registry && identity_token == mocked_identity_token:
+		case request.Service == mocked_anonymous_registry:
+			// anonymous tokens
+			return nil
+		case request.Credential.RefreshToken == mocked_identity_token:
 			// exchanged registry token
-			return mocked_registry_token, nil
+			return nil
+		case request.Flow == auth.FlowPassword && request.Credential.Username == "user" && request.Credential.Password == "pass:word":
+			// token of the password grant
+			return nil
 		}
-		return "", errors.New("invalid registry")
+		return errors.New("invalid credential")
 	}
 
 	type args struct {
@@ -33,8 +49,10 @@ func TestParseTokenOption(t *testing.T) {
 		registry    string
 	}
 	tests := []struct {
-		name    string
-		args    args
+		name string
+		args args
+		// want is the Authorization header, or the flow of the token
+		// requests if the tokens are requested by the instances
 		want    string
 		wantErr bool
 	}{
@@ -52,7 +70,7 @@ func TestParseTokenOption(t *testing.T) {
 				tokenOption: "anonymous",
 				registry:    mocked_anonymous_registry,
 			},
-			want:    mocked_identity_token,
+			want:    auth.FlowBearer,
 			wantErr: false,
 		},
 		{
@@ -79,7 +97,7 @@ func TestParseTokenOption(t *testing.T) {
 				tokenOption: "token=" + mocked_identity_token,
 				registry:    mocked_auth_registry,
 			},
-			want:    mocked_registry_token,
+			want:    auth.FlowBearer,
 			wantErr: false,
 		},
 		{
@@ -91,6 +109,60 @@ func TestParseTokenOption(t *testing.T) {
 			want:    "",
 			wantErr: true,
 		},
+		{
+			name: "Valid refresh_token grant",
+			args: args{
+				tokenOption: "refresh_token=" + mocked_identity_token,
+				registry:    mocked_auth_registry,
+			},
+			want:    auth.FlowRefreshToken,
+			wantErr: false,
+		},
+		{
+			name: "Valid password grant",
+			args: args{
+				tokenOption: "password=user:pass:word",
+				registry:    mocked_auth_registry,
+			},
+			want:    auth.FlowPassword,
+			wantErr: false,
+		},
+		{
+			name: "Password grant without password",
+			args: args{
+				tokenOption: "password=user",
+				registry:    mocked_auth_registry,
+			},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name: "Basic flow without username",
+			args: args{
+				tokenOption: "basic=:password",
+				registry:    mocked_auth_registry,
+			},
+			want:    "",
+			wantErr: true,
+		},
+		{
+			name: "Basic auth with a basic-only registry",
+			args: args{
+				tokenOption: "basic=user:pass",
+				registry:    mocked_basic_registry,
+			},
+			want:    auth.BasicAuthorization(auth.Credential{Username: "user", Password: "pass"}),
+			wantErr: false,
+		},
+		{
+			name: "Anonymous option with a basic-only registry",
+			args: args{
+				tokenOption: "anonymous",
+				registry:    mocked_basic_registry,
+			},
+			want:    "",
+			wantErr: true,
+		},
 		{
 			name:    "Empty token option",
 			args:    args{},
@@ -102,14 +174,18 @@ func TestParseTokenOption(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			token := &Token{}
 			token.SetFlag(tt.args.tokenOption)
-			err := token.Parse(tt.args.registry)
+			err := token.Parse(context.Background(), tt.args.registry)
 			if tt.wantErr {
 				if err == nil {
 					t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
 				}
 			} else {
-				if token.AccessToken != tt.want {
-					t.Errorf("Parse() = %v, want %v", token.AccessToken, tt.want)
+				got := token.Authorization
+				if token.TokenRequest != nil {
+					got = token.TokenRequest.Flow
+				}
+				if got != tt.want {
+					t.Errorf("Parse() = %v, want %v", got, tt.want)
 				}
 			}
 		})
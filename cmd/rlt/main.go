@@ -12,6 +12,12 @@ func main() {
 	if err := func() error {
 		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer cancel()
+		go func() {
+			// restore the default behavior so that a second interrupt
+			// terminates the process immediately
+			<-ctx.Done()
+			cancel()
+		}()
 		return root.New().ExecuteContext(ctx)
 	}(); err != nil {
 		os.Exit(1)
@@ -1,11 +1,19 @@
 package root
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"oras.land/oras-go/v2/registry"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
 	"github.com/billy-playground/registry-load-tester/cmd/internal/runner"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/schedule"
 	"github.com/billy-playground/registry-load-tester/cmd/option"
 	"github.com/billy-playground/registry-load-tester/internal/auth"
 	"github.com/spf13/cobra"
@@ -14,14 +22,25 @@ import (
 type authOptions struct {
 	option.Instance
 	option.Registry
-	refreshToken string
+	option.Seed
+	option.Soak
+	option.Limits
+	option.Timeouts
+	option.Retry
+	option.Output
+	option.Progress
+	option.Metrics
+	option.TimeSeries
+	option.Assertions
+	option.Credential
+	option.Assets
 }
 
 func authCmd() *cobra.Command {
 	var opts authOptions
 
 	authCmd := &cobra.Command{
-		Use:   "auth  <num_instances>[=<size>/<duration>] <registry_domain>",
+		Use:   "auth  <num_instances>[=<arrival>] <registry_domain>",
 		Short: "authenticate to a registry",
 		Long: `run authentication workloads simultaneously with customized options
 
@@ -31,42 +50,128 @@ Example - authenticate 10 images against registry.example.com without using any
 Example - authenticate 100 images against registry.example.com, starting 10 instances every 500 milliseconds using the specified token.
   rlt auth 100=10/500ms registry.example.com --refresh-token=$registry_token
 
+Example - authenticate 100 times against registry.example.com with the OAuth2 password grant.
+  rlt auth 100 registry.example.com --flow password -u $username -p $password
+
+Example - authenticate 100 times against registry.example.com with the OAuth2 refresh_token grant.
+  rlt auth 100 registry.example.com --flow refresh_token -r $refresh_token --client-id ci
+
+Example - authenticate 100 times against a registry only supporting basic authentication.
+  rlt auth 100 registry.example.com -u $username -p $password
+
+Example - authenticate 600 times against registry.example.com, starting 20 instances per second on average with Poisson arrivals.
+  rlt auth 600=poisson=20/s registry.example.com
+
+Example - authenticate against registry.example.com with a spike from 10 to 200 instances per second for 10 seconds.
+  rlt auth 100000=spike=10,200/s:1m,10s,2m registry.example.com
+
+Example - soak test the token service of registry.example.com for 30 minutes, keeping 50 exchanges in flight.
+  rlt auth --duration 30m --concurrency 50 registry.example.com
+
+Example - authenticate 100 times against registry.example.com, retrying throttled and failed exchanges up to 5 attempts.
+  rlt auth 100 registry.example.com --max-attempts 5
+
+Example - authenticate 100 times against registry.example.com for the repositories of the images of ./my-assets, picked with a Zipf distribution.
+  rlt auth 100 registry.example.com --assets ./my-assets --distribution zipf
+
+Example - authenticate 100 times against registry.example.com and write the results as JSON lines to results.jsonl.
+  rlt auth 100 registry.example.com --output-format jsonl -o results.jsonl
+
 Example - authenticate 20 images against registry.example.com via a custom endpoint -e cus.fe.example.com.
   rlt auth 20 registry.example.com none -e cus.fe.example.com
-`,
-		Args: cobra.ExactArgs(2),
+` + arrivalHelp,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.Soak.Enabled() {
+				// the instances are scheduled by the soak options
+				return cobra.ExactArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			// Setup arguments
-			opts.Instance.SetFlag(args[0])
-			opts.Registry.SetFlag(args[1])
+			if !opts.Soak.Enabled() {
+				opts.Instance.SetFlag(args[0])
+				args = args[1:]
+			}
+			opts.Registry.SetFlag(args[0])
 
 			// Parse options
-			if err := opts.Instance.Parse(); err != nil {
-				return fmt.Errorf("Error parsing instance option: %v\n", err)
+			if !opts.Soak.Enabled() {
+				if err := opts.Instance.Parse(); err != nil {
+					return fmt.Errorf("Error parsing instance option: %v\n", err)
+				}
+			}
+			if err := opts.Soak.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Limits.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Timeouts.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Seed.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Registry.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Retry.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Credential.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Assertions.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Assets.Parse(opts.Seed.Seed); err != nil {
+				return err
+			}
+			if err := opts.Output.Parse(result.OperationAuth); err != nil {
+				return err
+			}
+			if err := opts.TimeSeries.Parse(); err != nil {
+				return err
 			}
-			return opts.Registry.Parse()
+			return opts.Metrics.Parse(opts.RegistryDomain)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAuth(opts)
+			return runAuth(cmd.Context(), opts)
 		},
 	}
 
 	opts.Registry.ApplyFlags(authCmd.Flags())
-	authCmd.Flags().StringVarP(&opts.refreshToken, "refresh-token", "r", "", "Token used for refreshing")
+	opts.Seed.ApplyFlags(authCmd.Flags())
+	opts.Soak.ApplyFlags(authCmd.Flags())
+	opts.Limits.ApplyFlags(authCmd.Flags())
+	opts.Timeouts.ApplyFlags(authCmd.Flags())
+	opts.Retry.ApplyFlags(authCmd.Flags())
+	opts.Output.ApplyFlags(authCmd.Flags())
+	opts.Progress.ApplyFlags(authCmd.Flags())
+	opts.Metrics.ApplyFlags(authCmd.Flags())
+	opts.TimeSeries.ApplyFlags(authCmd.Flags())
+	opts.Assertions.ApplyFlags(authCmd.Flags())
+	opts.Credential.ApplyFlags(authCmd.Flags())
+	opts.Assets.ApplyFlags(authCmd.Flags())
 
 	return authCmd
 }
 
-func runAuth(opts authOptions) error {
-	fmt.Println("timestamp,is_success")
-
-	// Run instanceOption.Count in total
-	// TODO: isolate this
-	start := time.Now()
-	next := start
-	var wg sync.WaitGroup
+func runAuth(ctx context.Context, opts authOptions) (err error) {
+	defer func() {
+		if cerr := opts.Output.Close(); err == nil {
+			err = cerr
+		}
+		if cerr := opts.Metrics.Close(ctx); err == nil {
+			err = cerr
+		}
+		if cerr := opts.TimeSeries.Close(); err == nil {
+			err = cerr
+		}
+	}()
 
-	authHeader, err := auth.GetAuthHeader(opts.RegistryDomain)
+	authHeader, err := getAuthHeader(ctx, opts.RegistryDomain, opts.Request)
 	if err != nil {
 		return err
 	}
@@ -76,29 +181,76 @@ func runAuth(opts authOptions) error {
 		return nil
 	}
 
-	realm, service, err := auth.ParseRealmAndService(authHeader)
+	challenge, err := auth.ParseChallenge(authHeader)
 	if err != nil {
 		return fmt.Errorf("failed to parse auth header: %v", err)
 	}
+	if challenge.Scheme == "basic" {
+		if err := opts.Credential.BasicOnly(); err != nil {
+			return err
+		}
+	}
 
-	testRunner := runner.NewAuthRunner(realm, service, opts.RegistryDomain, opts.refreshToken)
-	batchingEnabled := opts.BatchSize > 0 && opts.BatchInterval > 0
-	for i := 0; i < opts.Count; i++ {
-		wg.Add(1)
-		if batchingEnabled && i%opts.BatchSize == 0 {
-			if toWait := next.Sub(time.Now()); toWait > 0 {
-				// wait for the next batch
-				time.Sleep(toWait)
-			}
-			next = time.Now().Add(opts.BatchInterval)
+	// Scope each token to the repository of a picked image
+	repositories, err := assetRepositories(opts.Images)
+	if err != nil {
+		return err
+	}
+	r := rand.New(rand.NewSource(opts.Seed.Seed))
+	var mu sync.Mutex
+	pick := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return repositories[opts.Selector.Select(r)]
+	}
+
+	// Run instanceOption.Count in total
+	monitor := startMonitor(opts.Progress, opts.Metrics.Exporter, opts.TimeSeries.Series)
+	testRunner := runner.NewAuthRunner(opts.RegistryDomain, challenge, opts.Credential.TokenRequest(challenge), runner.AuthOptions{
+		Timeouts: opts.Timeouts.Timeouts,
+		Results:  monitor.writer(opts.Results),
+	})
+	var started atomic.Int64
+	startNew := func(int) {
+		started.Add(1)
+		monitor.started()
+		_ = testRunner.StartNew(ctx, pick())
+	}
+	var planned int
+	if opts.Soak.Enabled() {
+		schedule.Soak(ctx, opts.Soak.Duration, opts.Concurrency, startNew)
+	} else {
+		offsets := opts.Arrival.Offsets(opts.Count, rand.New(rand.NewSource(opts.Seed.Seed)))
+		planned = len(offsets)
+		stats := schedule.Run(ctx, offsets, opts.MaxInstances, startNew)
+		reportSchedule(stats, opts.MaxInstances)
+	}
+	monitor.stop()
+	if err := checkInterrupted(ctx, int(started.Load()), planned); err != nil {
+		return err
+	}
+	return monitor.check(&opts.Assertions, result.OperationAuth)
+}
+
+// assetRepositories returns the repository of the manifest of each asset.
+func assetRepositories(assets []asset.Asset) ([]string, error) {
+	repositories := make([]string, len(assets))
+	for i, a := range assets {
+		ref, err := registry.ParseReference(a.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing manifest of %s: %v\n", a.Name, err)
 		}
-		go func() {
-			defer wg.Done()
-			err = testRunner.StartNew()
-			fmt.Printf("%s,%t\n", time.Now().Format(time.RFC3339), err == nil)
-		}()
+		repositories[i] = ref.Repository
+	}
+	return repositories, nil
+}
+
+// getAuthHeader gets the authentication challenge of the registry within the request timeout.
+func getAuthHeader(ctx context.Context, registry string, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	wg.Wait()
-	fmt.Printf("Total time taken: %.2f seconds\n", time.Since(start).Seconds())
-	return nil
+	return auth.GetAuthHeader(ctx, registry)
 }
@@ -1,9 +1,35 @@
 package root
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/metrics"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/progress"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/schedule"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/stats"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/timeseries"
+	"github.com/billy-playground/registry-load-tester/cmd/option"
 	"github.com/spf13/cobra"
 )
 
+// arrivalHelp describes the arrivals of the <num_instances>[=<arrival>] argument.
+const arrivalHelp = `
+Arrivals - when the instances start, all at once if omitted:
+  <size>/<interval>                       start size instances every interval, e.g. 100=10/500ms
+  rate=<n>/<unit>                         start n instances per unit at a constant rate, e.g. 600=rate=50/s
+  poisson=<n>/<unit>                      start n instances per unit on average with Poisson arrivals, e.g. 600=poisson=50/s
+  ramp=<from>,<to>/<unit>:<duration>      increase the rate linearly over the duration, e.g. 50000=ramp=10,200/s:10m
+  steps=<n1>,<n2>,.../<unit>:<hold>       hold each rate for the hold time, e.g. 50000=steps=10,20,40,80/s:2m
+  spike=<base>,<peak>/<unit>:<base_hold>,<spike_hold>,<recover_hold>
+                                          hold the base rate, spike to the peak rate and recover, e.g. 50000=spike=10,200/s:1m,10s,2m
+  Rate profiles (ramp, steps and spike) end with their last phase, even if fewer than num_instances instances have started.
+`
+
 func New() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:          "rlt [command]",
@@ -14,6 +40,104 @@ func New() *cobra.Command {
 	cmd.AddCommand(
 		authCmd(),
 		pullCmd(),
+		prepareCmd(),
+		planCmd(),
+		reportCmd(),
+		compareCmd(),
 	)
 	return cmd
 }
+
+// reportSchedule warns when the load generator could not keep up with the schedule.
+func reportSchedule(stats schedule.Stats, maxInstances int) {
+	if stats.Delayed == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %d of %d instances started late, up to %v, because all %d workers were busy. "+
+		"The load generator was the bottleneck; consider raising --max-instances.\n",
+		stats.Delayed, stats.Started, stats.MaxLag.Round(time.Millisecond), maxInstances)
+}
+
+// monitor observes the records of a run besides the results output: it
+// aggregates the summary and feeds the live progress display, the metrics
+// exporter and the time series.
+type monitor struct {
+	start    time.Time
+	elapsed  time.Duration
+	summary  *stats.Summary
+	display  *progress.Display
+	exporter *metrics.Exporter
+	series   *timeseries.Series
+}
+
+// startMonitor starts monitoring a run. The exporter and the series are nil
+// if the metrics and the time series are disabled.
+func startMonitor(progress option.Progress, exporter *metrics.Exporter, series *timeseries.Series) *monitor {
+	if series != nil {
+		series.Start()
+	}
+	return &monitor{
+		start:    time.Now(),
+		summary:  stats.NewSummary(),
+		display:  progress.Start(),
+		exporter: exporter,
+		series:   series,
+	}
+}
+
+// writer returns the writer of the records of the run, feeding both the
+// results output and the monitor.
+func (m *monitor) writer(output result.Writer) result.Writer {
+	writers := []result.Writer{output, m.summary}
+	if m.display != nil {
+		writers = append(writers, m.display)
+	}
+	if m.exporter != nil {
+		writers = append(writers, m.exporter)
+	}
+	if m.series != nil {
+		writers = append(writers, m.series)
+	}
+	return result.Multi(writers...)
+}
+
+// started counts a started instance.
+func (m *monitor) started() {
+	m.display.Started()
+	m.exporter.Started()
+	m.series.Started()
+}
+
+// stop stops the live progress display and the time series, and prints the
+// summary of the run to stderr.
+func (m *monitor) stop() {
+	m.display.Stop()
+	if err := m.series.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing timeseries: %v\n", err)
+	}
+	m.elapsed = time.Since(m.start)
+	fmt.Fprintf(os.Stderr, "Total time taken: %.2f seconds\n", m.elapsed.Seconds())
+	if err := m.summary.Print(os.Stderr, m.elapsed); err != nil {
+		fmt.Fprintf(os.Stderr, "Error printing summary: %v\n", err)
+	}
+}
+
+// check checks the assertions against the summary of the stopped run of
+// instances of the operation.
+func (m *monitor) check(assertions *option.Assertions, operation string) error {
+	return assertions.Check(m.summary.Operations(), m.elapsed, operation)
+}
+
+// checkInterrupted notes that the run was interrupted, in which case the
+// reported results only cover the instances started before the interruption.
+func checkInterrupted(ctx context.Context, started int, planned int) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if planned > 0 {
+		fmt.Fprintf(os.Stderr, "Run interrupted: %d of %d instances started, the results are partial.\n", started, planned)
+	} else {
+		fmt.Fprintf(os.Stderr, "Run interrupted: %d instances started, the results are partial.\n", started)
+	}
+	return errors.New("run interrupted")
+}
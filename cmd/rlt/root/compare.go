@@ -0,0 +1,82 @@
+package root
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/report"
+	"github.com/spf13/cobra"
+)
+
+type compareOptions struct {
+	latencyThreshold    float64
+	throughputThreshold float64
+	errorRateThreshold  float64
+	significance        float64
+}
+
+func compareCmd() *cobra.Command {
+	var opts compareOptions
+
+	compareCmd := &cobra.Command{
+		Use:   "compare <baseline_results_file> <candidate_results_file>",
+		Short: "compare the saved results of two runs",
+		Long: `compare the latency percentiles, throughputs and error rates of the operations of a candidate run with a
+baseline run, e.g. the same workload before and after a registry release, and exit with a non-zero code if the
+candidate regresses
+
+A latency percentile regresses when it grows over its threshold and the candidate latencies are significantly higher
+than the baseline ones per a one-sided Mann-Whitney U test. The error rate regresses when it grows over its threshold
+and the increase is significant per a one-sided two-proportion z-test. The throughput regresses when it drops over its
+threshold, a single rate being measured per run. The significance is not tested with fewer than 10 operations per run.
+
+Example - compare the results of two pull runs.
+  rlt compare baseline.jsonl candidate.jsonl
+
+Example - gate a release on latency increases under 5% and error rate increases under 0.1 percentage point.
+  rlt compare baseline.jsonl candidate.jsonl --latency-threshold 5 --error-rate-threshold 0.1
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCompare(args[0], args[1], opts)
+		},
+	}
+
+	compareCmd.Flags().Float64Var(&opts.latencyThreshold, "latency-threshold", 10, "Increase of the latency percentiles in percent from which they regress")
+	compareCmd.Flags().Float64Var(&opts.throughputThreshold, "throughput-threshold", 10, "Decrease of the throughputs in percent from which they regress")
+	compareCmd.Flags().Float64Var(&opts.errorRateThreshold, "error-rate-threshold", 0.5, "Increase of the error rates in percentage points from which they regress")
+	compareCmd.Flags().Float64Var(&opts.significance, "significance", 0.05, "P-value under which a change is statistically significant")
+
+	return compareCmd
+}
+
+func runCompare(baselineFile string, candidateFile string, opts compareOptions) error {
+	// the negated comparisons reject NaN too
+	if !(opts.latencyThreshold >= 0) || !(opts.throughputThreshold >= 0) || !(opts.errorRateThreshold >= 0) {
+		return fmt.Errorf("Regression thresholds must not be negative\n")
+	}
+	if !(opts.significance > 0 && opts.significance < 1) {
+		return fmt.Errorf("Significance must be greater than 0 and less than 1\n")
+	}
+	baseline, err := readResults([]string{baselineFile})
+	if err != nil {
+		return err
+	}
+	candidate, err := readResults([]string{candidateFile})
+	if err != nil {
+		return err
+	}
+	c := report.Compare(baseline, candidate, report.Thresholds{
+		Latency:      opts.latencyThreshold / 100,
+		Throughput:   opts.throughputThreshold / 100,
+		ErrorRate:    opts.errorRateThreshold / 100,
+		Significance: opts.significance,
+	})
+	if err := c.Table().Print(os.Stdout); err != nil {
+		return err
+	}
+	if n := c.Regressions(); n > 0 {
+		return fmt.Errorf("The candidate regresses on %d metrics\n", n)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package root
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/plan"
+	"github.com/billy-playground/registry-load-tester/cmd/option"
+	"github.com/spf13/cobra"
+)
+
+type planOptions struct {
+	option.Instance
+	option.Assets
+	option.Seed
+	output string
+}
+
+func planCmd() *cobra.Command {
+	var opts planOptions
+
+	planCmd := &cobra.Command{
+		Use:   "plan <num_instances>[=<arrival>]",
+		Short: "generate the execution plan of a pull workload",
+		Long: `generate the full schedule of a pull workload, which can be replayed with "rlt pull --plan"
+
+Example - plan 100 pulls, starting 10 instances every 500 milliseconds, and write the plan to plan.json.
+  rlt plan 100=10/500ms -o plan.json
+
+Example - plan 1000 pulls starting 20 instances per second on average with Poisson arrivals.
+  rlt plan 1000=poisson=20/s -o plan.json
+
+Example - plan 1000 pulls following a Zipf distribution with seed 42.
+  rlt plan 1000 --distribution zipf --seed 42 -o plan.json
+` + arrivalHelp,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			// Setup arguments
+			opts.Instance.SetFlag(args[0])
+
+			// Parse options
+			if err := opts.Instance.Parse(); err != nil {
+				return fmt.Errorf("Error parsing instance option: %v\n", err)
+			}
+			if err := opts.Seed.Parse(); err != nil {
+				return err
+			}
+			return opts.Assets.Parse(opts.Seed.Seed)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPlan(opts)
+		},
+	}
+
+	opts.Assets.ApplyFlags(planCmd.Flags())
+	opts.Seed.ApplyFlags(planCmd.Flags())
+	planCmd.Flags().StringVarP(&opts.output, "output", "o", "", "File to write the plan to (default: stdout)")
+
+	return planCmd
+}
+
+func runPlan(opts planOptions) error {
+	p := plan.Generate(opts.Count, opts.Arrival, opts.Seed.Seed, opts.Images, opts.Selector)
+	if opts.output == "" {
+		return p.Write(os.Stdout)
+	}
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return fmt.Errorf("Error creating plan file: %v\n", err)
+	}
+	if err := p.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("Error writing plan file: %v\n", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,90 @@
+package root
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/prepare"
+	"github.com/billy-playground/registry-load-tester/cmd/option"
+	"github.com/spf13/cobra"
+)
+
+type prepareOptions struct {
+	option.Registry
+	repositoriesFile string
+	outputDir        string
+	tagLimit         int
+	tagFilter        string
+	platform         string
+	plainHTTP        bool
+}
+
+func prepareCmd() *cobra.Command {
+	var opts prepareOptions
+
+	prepareCmd := &cobra.Command{
+		Use:   "prepare <registry_domain>",
+		Short: "generate image description JSON files from a registry",
+		Long: `generate the image description JSON files used by the pull workloads
+
+Example - generate JSON files for the first 5 tags of the repositories listed in prepare/repositories.json.
+  rlt prepare mcr.azure.cn
+
+Example - generate JSON files for up to 10 tags starting with "v" of each repository into a custom directory.
+  rlt prepare mcr.azure.cn --tag-limit 10 --tag-filter '^v' -o my-assets
+
+Example - generate JSON files for linux/arm64 images listed in a custom repository file.
+  rlt prepare registry.example.com --platform linux/arm64 --repositories my-repositories.json
+`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			opts.Registry.SetFlag(args[0])
+			if opts.tagLimit < 0 {
+				return fmt.Errorf("Tag limit must not be negative\n")
+			}
+			return opts.Registry.Parse()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPrepare(cmd, opts)
+		},
+	}
+
+	opts.Registry.ApplyFlags(prepareCmd.Flags())
+	prepareCmd.Flags().StringVar(&opts.repositoriesFile, "repositories", "prepare/repositories.json", "JSON file listing the repositories to process")
+	prepareCmd.Flags().StringVarP(&opts.outputDir, "output-dir", "o", "assets/images", "Directory to write the generated JSON files into")
+	prepareCmd.Flags().IntVar(&opts.tagLimit, "tag-limit", 5, "Maximum number of tags processed per repository, 0 for no limit")
+	prepareCmd.Flags().StringVar(&opts.tagFilter, "tag-filter", "", "Regular expression the processed tags must match")
+	prepareCmd.Flags().StringVar(&opts.platform, "platform", "linux/amd64", "Platform resolved from image indexes in the format <os>/<arch>[/<variant>][:<os_version>]")
+	prepareCmd.Flags().BoolVar(&opts.plainHTTP, "plain-http", false, "Access the registry via HTTP instead of HTTPS")
+
+	return prepareCmd
+}
+
+func runPrepare(cmd *cobra.Command, opts prepareOptions) error {
+	platform, err := prepare.ParsePlatform(opts.platform)
+	if err != nil {
+		return err
+	}
+	generator := &prepare.Generator{
+		Registry:  opts.RegistryDomain,
+		OutputDir: opts.outputDir,
+		TagLimit:  opts.tagLimit,
+		Platform:  platform,
+		PlainHTTP: opts.plainHTTP,
+		Log:       os.Stderr,
+	}
+	if opts.tagFilter != "" {
+		if generator.TagFilter, err = regexp.Compile(opts.tagFilter); err != nil {
+			return fmt.Errorf("invalid tag filter: %w", err)
+		}
+	}
+
+	repositories, err := prepare.ReadRepositories(opts.repositoriesFile)
+	if err != nil {
+		return fmt.Errorf("Error reading repositories: %v\n", err)
+	}
+	generated, err := generator.Run(cmd.Context(), repositories)
+	fmt.Fprintf(os.Stderr, "Generated %d JSON files in %s\n", generated, opts.outputDir)
+	return err
+}
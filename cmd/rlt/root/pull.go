@@ -1,13 +1,18 @@
 package root
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
-	"path/filepath"
+	"os"
 	"sync"
-	"time"
+	"sync/atomic"
 
+	"github.com/billy-playground/registry-load-tester/cmd/internal/asset"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/plan"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
 	"github.com/billy-playground/registry-load-tester/cmd/internal/runner"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/schedule"
 	"github.com/billy-playground/registry-load-tester/cmd/option"
 	"github.com/spf13/cobra"
 )
@@ -16,13 +21,32 @@ type pullOptions struct {
 	option.Instance
 	option.Registry
 	option.Token
+	option.Assets
+	option.Seed
+	option.Soak
+	option.Limits
+	option.Timeouts
+	option.Retry
+	option.Output
+	option.Progress
+	option.Metrics
+	option.TimeSeries
+	option.Assertions
+	planFile     string
+	maxFetches   int
+	verifyDigest bool
+}
+
+// scheduled returns whether the instances are not scheduled by the instance argument.
+func (opts *pullOptions) scheduled() bool {
+	return opts.planFile != "" || opts.Soak.Enabled()
 }
 
 func pullCmd() *cobra.Command {
 	var opts pullOptions
 
 	pullCmd := &cobra.Command{
-		Use:   "pull  <num_instances>[=<size>/<duration>] <registry_domain> <token_mode>",
+		Use:   "pull  <num_instances>[=<arrival>] <registry_domain> <token_mode>",
 		Short: "pull from a registry",
 		Long: `run pull workloads simultaneously with customized options
 
@@ -35,75 +59,248 @@ Example - pull 100 images against registry.example.com, starting 10 instances ev
 Example - pull 50 images against registry.example.com using shared anonymous access.
   rlt 50 registry.example.com anonymous
 
+Example - pull 100 images against registry.example.com with a token of the OAuth2 password grant.
+  rlt pull 100 registry.example.com password=$username:$password
+
+Example - pull 100 images against registry.example.com with a token of the OAuth2 refresh_token grant.
+  rlt pull 100 registry.example.com refresh_token=$refresh_token --client-id ci
+
+Example - pull 1000 images against registry.example.com, sharing the token of each repository between the instances.
+  rlt pull 1000 registry.example.com anonymous --token-cache
+
+Example - pull 100 images against a registry only supporting basic authentication.
+  rlt pull 100 registry.example.com basic=$username:$password
+
 Example - pull 20 images against registry.example.com via a custom endpoint -e cus.fe.example.com.
   rlt 20 registry.example.com none -e cus.fe.example.com
 
 Example - pull 20 images against registry.example.com via a customize IP 192.168.1.1.
   rlt 20 registry.example.com none -e 192.168.1.1
-`,
-		Args: cobra.MinimumNArgs(3),
+
+Example - pull 20 images described in a JSON-lines catalog against registry.example.com.
+  rlt pull 20 registry.example.com none --assets catalog.jsonl
+
+Example - pull 1000 images against registry.example.com where a few hot images dominate, following a Zipf distribution.
+  rlt pull 1000 registry.example.com none --distribution zipf=1.2
+
+Example - pull 1000 images against registry.example.com weighted by the "pulls" field of the image descriptions.
+  rlt pull 1000 registry.example.com none --distribution weight=pulls
+
+Example - pull 600 images against registry.example.com, starting 50 instances per second at a constant rate.
+  rlt pull 600=rate=50/s registry.example.com none
+
+Example - pull 600 images against registry.example.com, starting 50 instances per second on average with Poisson arrivals.
+  rlt pull 600=poisson=50/s registry.example.com none
+
+Example - find where registry.example.com degrades by ramping the pull rate from 10 to 200 instances per second over 10 minutes.
+  rlt pull 100000=ramp=10,200/s:10m registry.example.com anonymous
+
+Example - pull 100 images against registry.example.com, picking the same images as a previous run with seed 42.
+  rlt pull 100 registry.example.com none --seed 42
+
+Example - replay the plan generated by "rlt plan" against registry.example.com.
+  rlt pull --plan plan.json registry.example.com none
+
+Example - soak test registry.example.com for 2 hours, keeping 200 instances pulling at any time.
+  rlt pull --duration 2h --concurrency 200 registry.example.com anonymous
+
+Example - pull 100 images against registry.example.com, aborting requests which take over 1 minute or receive nothing for 10 seconds.
+  rlt pull 100 registry.example.com anonymous --request-timeout 1m --stall-timeout 10s
+
+Example - pull 100 images against registry.example.com, retrying like container runtimes do with up to 5 attempts per request.
+  rlt pull 100 registry.example.com anonymous --max-attempts 5 --retry-backoff 1s
+
+Example - pull 100 images against registry.example.com and show the results as a table.
+  rlt pull 100 registry.example.com anonymous --output-format table
+
+Example - pull 100 images against registry.example.com and write the results as JSON lines to results.jsonl.
+  rlt pull 100 registry.example.com anonymous --output-format jsonl -o results.jsonl
+
+Example - pull 100 images against registry.example.com and write a result for each manifest and blob fetch to fetches.csv.
+  rlt pull 100 registry.example.com anonymous --detailed -o fetches.csv
+
+Example - pull 50000 images against registry.example.com, keeping at most 2000 instances active and 4 fetches per instance.
+  rlt pull 50000=rate=500/s registry.example.com anonymous --max-instances 2000 --max-fetches 4
+
+Example - soak test registry.example.com for 8 hours, serving Prometheus metrics on port 9090 for dashboards.
+  rlt pull --duration 8h --concurrency 200 registry.example.com anonymous --metrics-addr :9090
+
+Example - pull 600 images against registry.example.com and write the activity of every 5 seconds to timeseries.csv.
+  rlt pull 600=rate=10/s registry.example.com anonymous --timeseries timeseries.csv --timeseries-interval 5s
+
+Example - gate a CI pipeline on the p99 latency of the blobs and the error rate of the pulls of registry.example.com.
+  rlt pull 100 registry.example.com anonymous --assert 'blob.p99<2s' --assert 'error_rate<0.5%'
+` + arrivalHelp,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if opts.scheduled() {
+				// the instances are scheduled by the plan or the soak options
+				return cobra.ExactArgs(2)(cmd, args)
+			}
+			return cobra.MinimumNArgs(3)(cmd, args)
+		},
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			// Setup arguments
-			opts.Instance.SetFlag(args[0])
-			opts.Registry.SetFlag(args[1])
-			opts.Token.SetFlag(args[2])
+			if !opts.scheduled() {
+				opts.Instance.SetFlag(args[0])
+				args = args[1:]
+			}
+			opts.Registry.SetFlag(args[0])
+			opts.Token.SetFlag(args[1])
 
 			// Parse options
-			if err := opts.Instance.Parse(); err != nil {
-				return fmt.Errorf("Error parsing instance option: %v\n", err)
+			if !opts.scheduled() {
+				if err := opts.Instance.Parse(); err != nil {
+					return fmt.Errorf("Error parsing instance option: %v\n", err)
+				}
+			}
+			if err := opts.Soak.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Limits.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Timeouts.Parse(); err != nil {
+				return err
+			}
+			if opts.maxFetches < 0 {
+				return fmt.Errorf("Maximum number of fetches must not be negative\n")
+			}
+			if err := opts.Seed.Parse(); err != nil {
+				return err
 			}
 			if err := opts.Registry.Parse(); err != nil {
 				return fmt.Errorf("Error parsing registry option: %v\n", err)
 			}
-			return opts.Token.Parse(opts.Registry.RegistryDomain)
+			if err := opts.Retry.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Assets.Parse(opts.Seed.Seed); err != nil {
+				return err
+			}
+			if err := opts.Token.Parse(cmd.Context(), opts.Registry.RegistryDomain); err != nil {
+				return err
+			}
+			if err := opts.Assertions.Parse(); err != nil {
+				return err
+			}
+			if err := opts.Output.Parse(result.OperationPull); err != nil {
+				return err
+			}
+			if err := opts.TimeSeries.Parse(); err != nil {
+				return err
+			}
+			return opts.Metrics.Parse(opts.RegistryDomain)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runPull(opts)
+			return runPull(cmd.Context(), opts)
 		},
 	}
 
-	opts.ApplyFlags(pullCmd.Flags())
+	opts.Registry.ApplyFlags(pullCmd.Flags())
+	opts.Assets.ApplyFlags(pullCmd.Flags())
+	opts.Token.ApplyFlags(pullCmd.Flags())
+	opts.Seed.ApplyFlags(pullCmd.Flags())
+	opts.Soak.ApplyFlags(pullCmd.Flags())
+	opts.Limits.ApplyFlags(pullCmd.Flags())
+	opts.Timeouts.ApplyFlags(pullCmd.Flags())
+	opts.Retry.ApplyFlags(pullCmd.Flags())
+	opts.Output.ApplyFlags(pullCmd.Flags())
+	opts.Output.ApplyDetailedFlag(pullCmd.Flags())
+	opts.Progress.ApplyFlags(pullCmd.Flags())
+	opts.Metrics.ApplyFlags(pullCmd.Flags())
+	opts.TimeSeries.ApplyFlags(pullCmd.Flags())
+	opts.Assertions.ApplyFlags(pullCmd.Flags())
+	opts.Timeouts.ApplyStallFlag(pullCmd.Flags())
+	pullCmd.Flags().IntVar(&opts.maxFetches, "max-fetches", 10, "Maximum number of concurrent manifest and blob fetches per instance, 0 for no limit")
+	pullCmd.Flags().BoolVar(&opts.verifyDigest, "verify-digest", false, "Verify the size and the digest of the fetched manifests and blobs, hashing all the fetched bytes")
+	pullCmd.Flags().StringVar(&opts.planFile, "plan", "", "Replay the instances scheduled in a plan file generated by \"rlt plan\"")
+	pullCmd.MarkFlagsMutuallyExclusive("plan", "duration")
 
 	return pullCmd
 }
 
-func runPull(opts pullOptions) error {
-	fmt.Println("json_file,total_size,download_milliseconds,total_count,success_count")
-	// Generate a random array of picked JSON files with length numInstances
-	files := make([]string, opts.Count)
+func runPull(ctx context.Context, opts pullOptions) (err error) {
+	defer func() {
+		if cerr := opts.Output.Close(); err == nil {
+			err = cerr
+		}
+		if cerr := opts.Metrics.Close(ctx); err == nil {
+			err = cerr
+		}
+		if cerr := opts.TimeSeries.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if opts.Soak.Enabled() {
+		return runPullSoak(ctx, opts)
+	}
 
-	allFiles, err := filepath.Glob(filepath.Join("assets/images", "*.json"))
+	// Schedule the picked images of all instances
+	var p *plan.Plan
+	if opts.planFile != "" {
+		if p, err = plan.Read(opts.planFile); err != nil {
+			return fmt.Errorf("Error reading plan: %v\n", err)
+		}
+	} else {
+		p = plan.Generate(opts.Count, opts.Arrival, opts.Seed.Seed, opts.Images, opts.Selector)
+	}
+	files, err := p.Resolve(opts.Images)
 	if err != nil {
-		return fmt.Errorf("Error reading image JSON files: %v\n", err)
+		return fmt.Errorf("Error resolving plan: %v\n", err)
 	}
-	if len(allFiles) == 0 {
-		return fmt.Errorf("No JSON files found in assets/images\n")
+	fmt.Fprintf(os.Stderr, "Seed: %d\n", p.Seed)
+
+	// Run all scheduled instances
+	monitor := startMonitor(opts.Progress, opts.Metrics.Exporter, opts.TimeSeries.Series)
+	testRunner := runner.NewPullRunner(opts.Token.Authorization, opts.RegistryDomain, runner.PullOptions{
+		MaxFetches:   opts.maxFetches,
+		VerifyDigest: opts.verifyDigest,
+		Timeouts:     opts.Timeouts.Timeouts,
+		TokenRequest: opts.Token.TokenRequest,
+		TokenCache:   opts.Token.TokenCache,
+		Results:      monitor.writer(opts.Results),
+	})
+	stats := schedule.Run(ctx, p.Offsets(), opts.MaxInstances, func(i int) {
+		monitor.started()
+		_ = testRunner.StartNew(ctx, files[i])
+	})
+	reportSchedule(stats, opts.MaxInstances)
+	monitor.stop()
+	if err := checkInterrupted(ctx, stats.Started, len(p.Entries)); err != nil {
+		return err
 	}
-	for i := range opts.Count {
-		files[i] = allFiles[rand.Intn(len(allFiles))]
+	return monitor.check(&opts.Assertions, result.OperationPull)
+}
+
+// runPullSoak keeps opts.Concurrency instances pulling until opts.Duration elapses.
+func runPullSoak(ctx context.Context, opts pullOptions) error {
+	fmt.Fprintf(os.Stderr, "Seed: %d\n", opts.Seed.Seed)
+	r := rand.New(rand.NewSource(opts.Seed.Seed))
+	var mu sync.Mutex
+	pick := func() asset.Asset {
+		mu.Lock()
+		defer mu.Unlock()
+		return opts.Images[opts.Selector.Select(r)]
 	}
 
-	// Run instanceOption.Count in total
-	start := time.Now()
-	next := start
-	var wg sync.WaitGroup
-	testRunner := runner.NewPullRunner(opts.Token.AccessToken, opts.RegistryDomain)
-	batchingEnabled := opts.BatchSize > 0 && opts.BatchInterval > 0
-	for i := 0; i < opts.Count; i++ {
-		wg.Add(1)
-		if batchingEnabled && i%opts.BatchSize == 0 {
-			if toWait := next.Sub(time.Now()); toWait > 0 {
-				// wait for the next batch
-				time.Sleep(toWait)
-			}
-			next = time.Now().Add(opts.BatchInterval)
-		}
-		go func() {
-			defer wg.Done()
-			_ = testRunner.StartNew(files[i])
-		}()
+	monitor := startMonitor(opts.Progress, opts.Metrics.Exporter, opts.TimeSeries.Series)
+	testRunner := runner.NewPullRunner(opts.Token.Authorization, opts.RegistryDomain, runner.PullOptions{
+		MaxFetches:   opts.maxFetches,
+		VerifyDigest: opts.verifyDigest,
+		Timeouts:     opts.Timeouts.Timeouts,
+		TokenRequest: opts.Token.TokenRequest,
+		TokenCache:   opts.Token.TokenCache,
+		Results:      monitor.writer(opts.Results),
+	})
+	var started atomic.Int64
+	schedule.Soak(ctx, opts.Soak.Duration, opts.Concurrency, func(int) {
+		started.Add(1)
+		monitor.started()
+		_ = testRunner.StartNew(ctx, pick())
+	})
+	monitor.stop()
+	if err := checkInterrupted(ctx, int(started.Load()), 0); err != nil {
+		return err
 	}
-	wg.Wait()
-	fmt.Printf("Total time taken: %.2f seconds\n", time.Since(start).Seconds())
-	return nil
+	return monitor.check(&opts.Assertions, result.OperationPull)
 }
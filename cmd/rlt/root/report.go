@@ -0,0 +1,97 @@
+package root
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/cmd/internal/report"
+	"github.com/billy-playground/registry-load-tester/cmd/internal/result"
+	"github.com/spf13/cobra"
+)
+
+type reportOptions struct {
+	html     string
+	interval time.Duration
+}
+
+func reportCmd() *cobra.Command {
+	var opts reportOptions
+
+	reportCmd := &cobra.Command{
+		Use:   "report <results_file>...",
+		Short: "summarise the saved results of runs",
+		Long: `summarise the results of auth and pull runs saved in the csv or jsonl format, with the latencies, the
+request phases and the errors of the operations, and the pulls broken down by repository and by size
+
+The pulls are broken down by repository with the detailed results of "rlt pull --detailed", by asset otherwise.
+The charts of the HTML report require timestamps, missing from the csv results of older versions.
+
+Example - summarise the results of a pull run.
+  rlt report results.csv
+
+Example - summarise the detailed results of a pull run and the results of an auth run in an HTML report.
+  rlt report fetches.jsonl auth.csv --html report.html
+
+Example - chart the results of a long run per minute.
+  rlt report results.jsonl --html report.html --interval 1m
+`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReport(args, opts)
+		},
+	}
+
+	reportCmd.Flags().StringVar(&opts.html, "html", "", "File to write a self-contained HTML report with charts to")
+	reportCmd.Flags().DurationVar(&opts.interval, "interval", 0, "Interval of the charts (default: picked from the span of the results)")
+
+	return reportCmd
+}
+
+func runReport(files []string, opts reportOptions) error {
+	if opts.interval < 0 {
+		return fmt.Errorf("Report interval must not be negative\n")
+	}
+	records, err := readResults(files)
+	if err != nil {
+		return err
+	}
+	r, err := report.New(records, opts.interval)
+	if err != nil {
+		return fmt.Errorf("Error building report: %v\n", err)
+	}
+	if err := r.Print(os.Stdout); err != nil {
+		return err
+	}
+	if opts.html == "" {
+		return nil
+	}
+	f, err := os.Create(opts.html)
+	if err != nil {
+		return fmt.Errorf("Error creating HTML report: %v\n", err)
+	}
+	if err := r.WriteHTML(f, strings.Join(files, ", ")); err != nil {
+		f.Close()
+		return fmt.Errorf("Error writing HTML report: %v\n", err)
+	}
+	return f.Close()
+}
+
+// readResults reads the records of the results files.
+func readResults(files []string) ([]result.Record, error) {
+	var records []result.Record
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening results %q: %v\n", file, err)
+		}
+		rs, err := result.Read(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading results %q: %v\n", file, err)
+		}
+		records = append(records, rs...)
+	}
+	return records, nil
+}
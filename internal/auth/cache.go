@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RepositoryScope returns the scope of the tokens pulling from a repository.
+func RepositoryScope(repository string) string {
+	return fmt.Sprintf("repository:%s:pull", repository)
+}
+
+// TokenCache caches tokens per scope. It is safe for concurrent use, the
+// concurrent requests of the token of a scope waiting for the first one.
+// The cached tokens are refreshed at their first use within the expiry margin
+// and once invalidated.
+type TokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*cachedToken
+}
+
+// cachedToken is a cached token, available once ready is closed.
+type cachedToken struct {
+	ready chan struct{}
+	token Token
+	err   error
+	// stale is set once the token is rejected.
+	stale bool
+}
+
+// done reports whether the token is available.
+func (t *cachedToken) done() bool {
+	select {
+	case <-t.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewTokenCache returns an empty token cache.
+func NewTokenCache() *TokenCache {
+	return &TokenCache{tokens: make(map[string]*cachedToken)}
+}
+
+// Get returns the cached token of the scope, fetching it with fetch if it is
+// not cached yet, expiring or invalidated, the token being refreshed passed to
+// fetch in the latter cases, nil otherwise. An expiring token is refreshed by
+// the first Get within the last tenth of its lifetime, not in the background.
+// It reports whether the token was fetched by this call. Failed fetches are
+// not cached, the next call fetching the token again.
+func (c *TokenCache) Get(ctx context.Context, scope string, fetch func(previous *Token) (Token, error)) (token Token, fetched bool, err error) {
+	c.mu.Lock()
+	entry, ok := c.tokens[scope]
+	var previous *Token
+	if ok && entry.done() && (entry.stale || entry.token.Expiring(time.Now())) {
+		ok, previous = false, &entry.token
+	}
+	if !ok {
+		entry = &cachedToken{ready: make(chan struct{})}
+		c.tokens[scope] = entry
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-entry.ready:
+			return entry.token, false, entry.err
+		case <-ctx.Done():
+			return Token{}, false, ctx.Err()
+		}
+	}
+
+	entry.token, entry.err = fetch(previous)
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.tokens[scope] == entry {
+			delete(c.tokens, scope)
+		}
+		c.mu.Unlock()
+	}
+	close(entry.ready)
+	return entry.token, true, entry.err
+}
+
+// Invalidate marks the token of the scope as rejected, if it is still cached,
+// for the next Get to refresh it.
+func (c *TokenCache) Invalidate(scope string, accessToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.tokens[scope]; ok && entry.done() && entry.token.AccessToken == accessToken {
+		entry.stale = true
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTokenCache(t *testing.T) {
+	c := NewTokenCache()
+	var fetches, refreshes int
+	get := func(expiresIn time.Duration) Token {
+		token, _, err := c.Get(context.Background(), "repository:a:pull", func(previous *Token) (Token, error) {
+			fetches++
+			if previous != nil {
+				refreshes++
+			}
+			return Token{AccessToken: fmt.Sprintf("t%d", fetches), IssuedAt: time.Now(), ExpiresIn: expiresIn}, nil
+		})
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return token
+	}
+
+	if token := get(time.Hour); token.AccessToken != "t1" {
+		t.Errorf("Get() = %s, want t1", token.AccessToken)
+	}
+	if token := get(time.Hour); token.AccessToken != "t1" {
+		t.Errorf("Get() of the cached token = %s, want t1", token.AccessToken)
+	}
+
+	// a rejected token is refreshed
+	c.Invalidate("repository:a:pull", "t1")
+	if token := get(0); token.AccessToken != "t2" {
+		t.Errorf("Get() of the invalidated token = %s, want t2", token.AccessToken)
+	}
+	// an expiring token is refreshed
+	if token := get(time.Hour); token.AccessToken != "t3" {
+		t.Errorf("Get() of the expiring token = %s, want t3", token.AccessToken)
+	}
+	if fetches != 3 || refreshes != 2 {
+		t.Errorf("got %d fetches and %d refreshes, want 3 fetches and 2 refreshes", fetches, refreshes)
+	}
+}
@@ -2,22 +2,40 @@ package auth
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+
+	"github.com/billy-playground/registry-load-tester/internal/retry"
 )
 
+// StatusError is returned when the registry or the token service responds
+// with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
 // GetAuthHeader tries to authenticate with the registry and get the authentication header.
 // If the authentication is successful, it returns the an empty challenge.
-func GetAuthHeader(registry string) (string, error) {
-	req, err := http.NewRequest("HEAD", fmt.Sprintf("https://%s/v2/", registry), nil)
+// Like the pulls, the registry is always accessed via HTTPS.
+func GetAuthHeader(ctx context.Context, registry string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", fmt.Sprintf("https://%s/v2/", registry), nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %v", err)
 	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to perform request: %v", err)
+		return "", fmt.Errorf("failed to perform request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -26,7 +44,7 @@ func GetAuthHeader(registry string) (string, error) {
 	}
 
 	if resp.StatusCode != http.StatusUnauthorized {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return "", fmt.Errorf("unexpected status code: %w", &StatusError{StatusCode: resp.StatusCode})
 	}
 
 	authHeader := resp.Header.Get("Www-Authenticate")
@@ -36,55 +54,253 @@ func GetAuthHeader(registry string) (string, error) {
 	return authHeader, nil
 }
 
-// ExchangeToken exchanges the token using native Go HTTP client.
-func ExchangeToken(realm string, service string, token string) (string, error) {
-	// Get the token using native Go HTTP client
-	tokenURL := fmt.Sprintf("%s?service=%s&scope=repository:*:pull", realm, service)
-	req, err := http.NewRequest("GET", tokenURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create exchange token request: %v", err)
-	}
-	if token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+// Flows of the token requests.
+const (
+	// FlowBearer gets a token with a GET request, sending the refresh token,
+	// if any, as a bearer token.
+	FlowBearer = "bearer"
+	// FlowBasic gets a token with a GET request authenticated with basic
+	// auth, as in the distribution token authentication spec.
+	FlowBasic = "basic"
+	// FlowRefreshToken gets a token with a form-encoded POST request of the
+	// OAuth2 refresh_token grant.
+	FlowRefreshToken = "refresh_token"
+	// FlowPassword gets a token with a form-encoded POST request of the
+	// OAuth2 password grant.
+	FlowPassword = "password"
+)
+
+// Flows lists the supported token flows.
+var Flows = []string{FlowBearer, FlowBasic, FlowRefreshToken, FlowPassword}
+
+// Credential is the credential a token is requested with.
+type Credential struct {
+	Username     string
+	Password     string
+	RefreshToken string
+}
+
+// TokenRequest is a request of a token to the token service of a registry.
+type TokenRequest struct {
+	Flow    string
+	Realm   string
+	Service string
+	Scopes  []string
+	// ClientID identifies the client in the OAuth2 flows.
+	ClientID   string
+	Credential Credential
+}
+
+// Refresh returns the request refreshing a token issued by an OAuth2 grant
+// with the refresh token issued along with it, for the credential not to be
+// sent again. The other requests are returned as is, to be sent again.
+func (r TokenRequest) Refresh(refreshToken string) TokenRequest {
+	if refreshToken == "" || (r.Flow != FlowRefreshToken && r.Flow != FlowPassword) {
+		return r
 	}
+	r.Flow = FlowRefreshToken
+	r.Credential = Credential{RefreshToken: refreshToken}
+	return r
+}
+
+// defaultExpiresIn is the lifetime of the tokens issued without expires_in,
+// as in the distribution token authentication spec.
+const defaultExpiresIn = 60 * time.Second
+
+// Token is a token issued by a token service.
+type Token struct {
+	AccessToken string
+	// RefreshToken is the refresh token issued along with the access token,
+	// if any, to refresh it with TokenRequest.Refresh.
+	RefreshToken string
+	// IssuedAt is the time the token was issued at, the time it was received
+	// if not returned by the token service.
+	IssuedAt time.Time
+	// ExpiresIn is the lifetime of the token from IssuedAt.
+	ExpiresIn time.Duration
+}
+
+// Expiry returns the time the token expires at.
+func (t Token) Expiry() time.Time {
+	return t.IssuedAt.Add(t.ExpiresIn)
+}
+
+// Expiring reports whether the token is in the last tenth of its lifetime at
+// now, i.e. whether it should be refreshed before it expires.
+func (t Token) Expiring(now time.Time) bool {
+	return !now.Before(t.Expiry().Add(-t.ExpiresIn / 10))
+}
 
+// tokenResponse is the response of a token service. The distribution token
+// authentication spec names the access token token, OAuth2 access_token.
+type tokenResponse struct {
+	Token        string `json:"token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IssuedAt     string `json:"issued_at"`
+}
+
+// FetchToken requests a token to the token service with the flow of the request.
+func FetchToken(ctx context.Context, r TokenRequest) (Token, error) {
+	req, err := r.newRequest(ctx)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to create token request: %v", err)
+	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to perform token request: %v", err)
+		return Token{}, fmt.Errorf("failed to perform token request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code while fetching token: %d", resp.StatusCode)
+		return Token{}, fmt.Errorf("unexpected status code while fetching token: %w", &StatusError{StatusCode: resp.StatusCode})
 	}
 
 	var buf bytes.Buffer
 	if _, err := buf.ReadFrom(resp.Body); err != nil {
-		return "", fmt.Errorf("failed to read token response body: %v", err)
+		return Token{}, fmt.Errorf("failed to read token response body: %w", err)
 	}
 
-	var result map[string]interface{}
+	var result tokenResponse
 	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
-		return "", fmt.Errorf("failed to parse token response JSON: %v", err)
+		return Token{}, fmt.Errorf("failed to parse token response JSON: %v", err)
 	}
-
-	token, ok := result["access_token"].(string)
-	if !ok {
-		return "", fmt.Errorf("access_token not found or invalid in response")
+	token := Token{
+		AccessToken:  result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		IssuedAt:     time.Now(),
+		ExpiresIn:    defaultExpiresIn,
+	}
+	if token.AccessToken == "" {
+		token.AccessToken = result.Token
+	}
+	if token.AccessToken == "" {
+		return Token{}, fmt.Errorf("access_token not found or invalid in response")
+	}
+	if result.ExpiresIn > 0 {
+		token.ExpiresIn = time.Duration(result.ExpiresIn) * time.Second
+	}
+	// an invalid issued_at is ignored, and so is an issued_at ahead of the
+	// clock, the token service's clock being off
+	if issuedAt, err := time.Parse(time.RFC3339, result.IssuedAt); err == nil && issuedAt.Before(token.IssuedAt) {
+		token.IssuedAt = issuedAt
 	}
 	return token, nil
 }
 
-// ParseRealmAndService gets the realm and service from the auth header.
-func ParseRealmAndService(authHeader string) (string, string, error) {
-	// Parse the realm and service from the auth header
-	realm := parseChallenge(authHeader, "realm")
-	service := parseChallenge(authHeader, "service")
-	// Parse the realm and service from the auth header
-	if realm == "" || service == "" {
-		return "", "", fmt.Errorf("failed to parse realm or service from auth header")
+// newRequest creates the HTTP request of the flow.
+func (r TokenRequest) newRequest(ctx context.Context) (*http.Request, error) {
+	switch r.Flow {
+	case FlowBearer, FlowBasic:
+		realm, err := url.Parse(r.Realm)
+		if err != nil {
+			return nil, err
+		}
+		query := realm.Query()
+		if r.Service != "" {
+			query.Set("service", r.Service)
+		}
+		for _, scope := range r.Scopes {
+			query.Add("scope", scope)
+		}
+		realm.RawQuery = query.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if r.Flow == FlowBasic {
+			req.SetBasicAuth(r.Credential.Username, r.Credential.Password)
+		} else if r.Credential.RefreshToken != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", r.Credential.RefreshToken))
+		}
+		return req, nil
+	case FlowRefreshToken, FlowPassword:
+		form := url.Values{
+			"grant_type": {r.Flow},
+			"client_id":  {r.ClientID},
+		}
+		if r.Service != "" {
+			form.Set("service", r.Service)
+		}
+		if len(r.Scopes) > 0 {
+			form.Set("scope", strings.Join(r.Scopes, " "))
+		}
+		if r.Flow == FlowRefreshToken {
+			form.Set("refresh_token", r.Credential.RefreshToken)
+		} else {
+			form.Set("username", r.Credential.Username)
+			form.Set("password", r.Credential.Password)
+		}
+		// the grants issue tokens without side effects, they can be retried
+		req, err := http.NewRequestWithContext(retry.WithReplayable(ctx), http.MethodPost, r.Realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	default:
+		return nil, fmt.Errorf("unknown token flow %q", r.Flow)
+	}
+}
+
+// BasicAuthorization returns the value of the Authorization header of the
+// basic authentication with the credential.
+func BasicAuthorization(c Credential) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+}
+
+// Ping sends an authorized request to the registry, e.g. to check basic
+// authentication with registries which do not issue tokens. The registry is
+// always accessed via HTTPS.
+func Ping(ctx context.Context, registry string, authorization string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/", registry), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %w", &StatusError{StatusCode: resp.StatusCode})
+	}
+	return nil
+}
+
+// Challenge is the authentication challenge of a registry.
+type Challenge struct {
+	// Scheme is the lower-case scheme of the challenge, e.g. bearer or basic.
+	Scheme  string
+	Realm   string
+	Service string
+}
+
+// ParseChallenge parses the WWW-Authenticate header of a registry. Bearer
+// challenges must have a realm to request tokens from.
+func ParseChallenge(authHeader string) (Challenge, error) {
+	scheme, _, _ := strings.Cut(strings.TrimSpace(authHeader), " ")
+	c := Challenge{
+		Scheme:  strings.ToLower(scheme),
+		Realm:   parseChallenge(authHeader, "realm"),
+		Service: parseChallenge(authHeader, "service"),
+	}
+	switch c.Scheme {
+	case "basic":
+		return c, nil
+	case "bearer":
+		if c.Realm == "" {
+			return Challenge{}, fmt.Errorf("failed to parse realm from auth header")
+		}
+		return c, nil
+	default:
+		return Challenge{}, fmt.Errorf("unsupported auth scheme %q", scheme)
 	}
-	return realm, service, nil
 }
 
 // parseChallenge extracts the value of a specific key from the WWW-Authenticate header
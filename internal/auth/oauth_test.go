@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var got string
+		switch r.Method {
+		case http.MethodGet:
+			if username, password, ok := r.BasicAuth(); ok {
+				got = "basic " + username + ":" + password
+			} else {
+				got = "bearer " + r.Header.Get("Authorization")
+			}
+			got += " " + r.URL.Query().Get("scope")
+			// the distribution token authentication spec names the token token
+			json.NewEncoder(w).Encode(map[string]string{"token": got})
+			return
+		case http.MethodPost:
+			if err := r.ParseForm(); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			got = r.PostForm.Get("grant_type") + " " + r.PostForm.Get("client_id") + " " + r.PostForm.Get("scope")
+			switch r.PostForm.Get("grant_type") {
+			case FlowRefreshToken:
+				got += " " + r.PostForm.Get("refresh_token")
+			case FlowPassword:
+				got += " " + r.PostForm.Get("username") + ":" + r.PostForm.Get("password")
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": got, "refresh_token": "refreshed"})
+	}))
+	defer server.Close()
+
+	credential := Credential{Username: "user", Password: "pass", RefreshToken: "rt"}
+	tests := []struct {
+		flow string
+		want string
+	}{
+		{FlowBearer, "bearer Bearer rt repository:a:pull"},
+		{FlowBasic, "basic user:pass repository:a:pull"},
+		{FlowRefreshToken, "refresh_token rlt repository:a:pull repository:b:pull rt"},
+		{FlowPassword, "password rlt repository:a:pull repository:b:pull user:pass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flow, func(t *testing.T) {
+			scopes := []string{"repository:a:pull"}
+			if tt.flow == FlowRefreshToken || tt.flow == FlowPassword {
+				scopes = append(scopes, "repository:b:pull")
+			}
+			token, err := FetchToken(context.Background(), TokenRequest{
+				Flow:       tt.flow,
+				Realm:      server.URL,
+				Service:    "registry",
+				Scopes:     scopes,
+				ClientID:   "rlt",
+				Credential: credential,
+			})
+			if err != nil {
+				t.Fatalf("FetchToken() error = %v", err)
+			}
+			if token.AccessToken != tt.want {
+				t.Errorf("FetchToken() = %q, want %q", token.AccessToken, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenRequestRefresh(t *testing.T) {
+	credential := Credential{Username: "user", Password: "pass", RefreshToken: "rt"}
+	tests := []struct {
+		flow         string
+		refreshToken string
+		want         TokenRequest
+	}{
+		{FlowPassword, "issued", TokenRequest{Flow: FlowRefreshToken, Credential: Credential{RefreshToken: "issued"}}},
+		{FlowRefreshToken, "issued", TokenRequest{Flow: FlowRefreshToken, Credential: Credential{RefreshToken: "issued"}}},
+		{FlowPassword, "", TokenRequest{Flow: FlowPassword, Credential: credential}},
+		{FlowBearer, "issued", TokenRequest{Flow: FlowBearer, Credential: credential}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flow+" "+tt.refreshToken, func(t *testing.T) {
+			got := TokenRequest{Flow: tt.flow, Credential: credential}.Refresh(tt.refreshToken)
+			if got.Flow != tt.want.Flow || got.Credential != tt.want.Credential {
+				t.Errorf("Refresh() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchTokenExpiry(t *testing.T) {
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("scope") {
+		case "issued":
+			json.NewEncoder(w).Encode(map[string]any{"token": "t", "expires_in": 300, "issued_at": issuedAt.Format(time.RFC3339)})
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"token": "t"})
+		}
+	}))
+	defer server.Close()
+
+	token, err := FetchToken(context.Background(), TokenRequest{Flow: FlowBearer, Realm: server.URL, Scopes: []string{"issued"}})
+	if err != nil {
+		t.Fatalf("FetchToken() error = %v", err)
+	}
+	expiry := issuedAt.Add(5 * time.Minute)
+	if !token.Expiry().Equal(expiry) {
+		t.Errorf("Expiry() = %v, want %v", token.Expiry(), expiry)
+	}
+	if token.Expiring(time.Now()) || !token.Expiring(expiry.Add(-time.Second)) {
+		t.Errorf("Expiring() should hold in the last tenth of the lifetime only")
+	}
+
+	// the tokens without expires_in last 60 seconds
+	token, err = FetchToken(context.Background(), TokenRequest{Flow: FlowBearer, Realm: server.URL})
+	if err != nil {
+		t.Fatalf("FetchToken() error = %v", err)
+	}
+	if token.ExpiresIn != defaultExpiresIn {
+		t.Errorf("ExpiresIn = %v, want %v", token.ExpiresIn, defaultExpiresIn)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    Challenge
+		wantErr bool
+	}{
+		{
+			header: `Bearer realm="https://auth.example.com/token",service="registry.example.com"`,
+			want:   Challenge{Scheme: "bearer", Realm: "https://auth.example.com/token", Service: "registry.example.com"},
+		},
+		{header: `Basic realm="registry"`, want: Challenge{Scheme: "basic", Realm: "registry"}},
+		{header: `Bearer service="registry.example.com"`, wantErr: true},
+		{header: `Negotiate`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, err := ParseChallenge(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChallenge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseChallenge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetryableStatus is the default set of status codes worth retrying.
+var DefaultRetryableStatus = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Policy describes when and how failed requests are retried.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts of a request, including
+	// the first one. A value of 1 or less disables the retries.
+	MaxAttempts int
+	// InitialBackoff is the backoff before the first retry, doubled after
+	// each retry up to MaxBackoff, or without limit if MaxBackoff is 0. A
+	// random jitter of up to half the backoff is subtracted.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RetryableStatus is the set of response status codes which are retried.
+	RetryableStatus map[int]bool
+	// HonorRetryAfter waits for the delay of the Retry-After header of
+	// retryable responses instead of the backoff, when present, up to
+	// MaxBackoff.
+	HonorRetryAfter bool
+}
+
+// Backoff returns the backoff before the given retry, starting from 1.
+func (p *Policy) Backoff(retry int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < retry && (p.MaxBackoff == 0 || backoff < p.MaxBackoff) && backoff < math.MaxInt64/2; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, p.MaxBackoff)
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff - time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// Transport retries the idempotent requests failing with a retryable status
+// code or a transport error, according to the policy. The POST requests with
+// a replayable body are retried too if their context is marked by
+// WithReplayable.
+type Transport struct {
+	Base   http.RoundTripper
+	Policy Policy
+}
+
+// RoundTrip sends the request, retrying it according to the policy.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	replayable, _ := req.Context().Value(replayableKey{}).(bool)
+	retryable := t.Policy.MaxAttempts > 1 &&
+		(req.Method == http.MethodGet || req.Method == http.MethodHead || (req.Method == http.MethodPost && replayable && req.GetBody != nil)) &&
+		(req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+	if !retryable {
+		return base.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	counter, _ := ctx.Value(counterKey{}).(*Counter)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+		resp, err := base.RoundTrip(req)
+		if attempt >= t.Policy.MaxAttempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			delay = t.Policy.Backoff(attempt)
+		case t.Policy.RetryableStatus[resp.StatusCode]:
+			delay = t.Policy.Backoff(attempt)
+			if t.Policy.HonorRetryAfter {
+				if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+					delay = retryAfter
+					if t.Policy.MaxBackoff > 0 {
+						delay = min(delay, t.Policy.MaxBackoff)
+					}
+				}
+			}
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		if counter != nil {
+			counter.retries.Add(1)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// parseRetryAfter parses the Retry-After header, either in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// Counter counts the retries of the requests sent with a context.
+type Counter struct {
+	retries atomic.Int64
+}
+
+// Retries returns the number of retries.
+func (c *Counter) Retries() int64 {
+	return c.retries.Load()
+}
+
+type counterKey struct{}
+
+type replayableKey struct{}
+
+// WithReplayable returns a context marking the POST requests sent with it as
+// safe to retry, e.g. the token requests of the OAuth2 grants.
+func WithReplayable(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayableKey{}, true)
+}
+
+// WithCounter returns a context counting the retries of the requests sent with it.
+func WithCounter(ctx context.Context) (context.Context, *Counter) {
+	counter := &Counter{}
+	return context.WithValue(ctx, counterKey{}, counter), counter
+}
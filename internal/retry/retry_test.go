@@ -0,0 +1,171 @@
+package retry
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		switch r.URL.Path {
+		case "/throttled":
+			if n < 3 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "/throttled-long":
+			// the delay is capped by the maximum backoff
+			if n < 2 {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{Policy: Policy{
+		MaxAttempts:     3,
+		InitialBackoff:  time.Millisecond,
+		MaxBackoff:      time.Millisecond,
+		RetryableStatus: map[int]bool{http.StatusTooManyRequests: true, http.StatusServiceUnavailable: true},
+		HonorRetryAfter: true,
+	}}}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantRequests int32
+	}{
+		{name: "Eventual success after throttling", path: "/throttled", wantStatus: http.StatusOK, wantRequests: 3},
+		{name: "Retry-After over the maximum backoff", path: "/throttled-long", wantStatus: http.StatusOK, wantRequests: 2},
+		{name: "Non-retryable status", path: "/missing", wantStatus: http.StatusNotFound, wantRequests: 1},
+		{name: "Attempts exhausted", path: "/unavailable", wantStatus: http.StatusServiceUnavailable, wantRequests: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			ctx, counter := WithCounter(context.Background())
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Do() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", got, tt.wantRequests)
+			}
+			if got := counter.Retries(); got != int64(tt.wantRequests-1) {
+				t.Errorf("Retries() = %d, want %d", got, tt.wantRequests-1)
+			}
+		})
+	}
+}
+
+func TestTransportPost(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "grant_type=password" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{Policy: Policy{
+		MaxAttempts:     3,
+		RetryableStatus: map[int]bool{http.StatusServiceUnavailable: true},
+	}}}
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		wantStatus   int
+		wantRequests int32
+	}{
+		{name: "Replayable grant", ctx: WithReplayable(context.Background()), wantStatus: http.StatusOK, wantRequests: 2},
+		{name: "Unmarked POST", ctx: context.Background(), wantStatus: http.StatusServiceUnavailable, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			req, err := http.NewRequestWithContext(tt.ctx, http.MethodPost, server.URL, strings.NewReader("grant_type=password"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Do() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		maxBackoff time.Duration
+		retry      int
+		max        time.Duration
+	}{
+		{maxBackoff: time.Second, retry: 1, max: 100 * time.Millisecond},
+		{maxBackoff: time.Second, retry: 2, max: 200 * time.Millisecond},
+		{maxBackoff: time.Second, retry: 3, max: 400 * time.Millisecond},
+		{maxBackoff: time.Second, retry: 10, max: time.Second},
+		{maxBackoff: 0, retry: 3, max: 400 * time.Millisecond},
+		{maxBackoff: 0, retry: 10, max: 51200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		p := Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: tt.maxBackoff}
+		for range 100 {
+			if got := p.Backoff(tt.retry); got < tt.max/2 || got > tt.max {
+				t.Fatalf("Backoff(%d) = %v, want within [%v, %v]", tt.retry, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got, ok := parseRetryAfter("3"); !ok || got != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %v, %v", got, ok)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got, ok := parseRetryAfter(date); !ok || got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(%s) = %v, %v", date, got, ok)
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("parseRetryAfter(soon) succeeded")
+	}
+}
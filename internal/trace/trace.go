@@ -0,0 +1,195 @@
+package trace
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing is the timing of the phases of an HTTP request. Phases which did not
+// happen, e.g. the DNS lookup and the connection of a reused connection, are 0.
+type Timing struct {
+	Method string    `json:"method"`
+	Host   string    `json:"host"`
+	Start  time.Time `json:"start"`
+	Status int       `json:"status,omitempty"`
+	// DNS is the duration of the DNS lookup.
+	DNS time.Duration `json:"dns_ns"`
+	// Connect is the duration of the TCP connection.
+	Connect time.Duration `json:"connect_ns"`
+	// TLS is the duration of the TLS handshake.
+	TLS time.Duration `json:"tls_ns"`
+	// TTFB is the time to first byte, from the request being written to the
+	// first byte of the response.
+	TTFB time.Duration `json:"ttfb_ns"`
+	// Transfer is the duration of the response body transfer, from the first
+	// byte of the response to the end of the body.
+	Transfer time.Duration `json:"transfer_ns"`
+	// Reused tells whether the request was sent over a reused connection.
+	Reused bool `json:"reused"`
+}
+
+// Recorder records the timings of the requests sent with a context.
+type Recorder struct {
+	mu      sync.Mutex
+	timings []Timing
+}
+
+// Timings returns the timings of the completed requests.
+func (r *Recorder) Timings() []Timing {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Timing(nil), r.timings...)
+}
+
+func (r *Recorder) add(t Timing) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timings = append(r.timings, t)
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context recording the timings of the requests sent with it.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	recorder := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, recorder), recorder
+}
+
+// Transport traces the requests sent with a context returned by WithRecorder.
+// It should be the innermost transport so that each retry and redirect is
+// traced as a separate request.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip sends the request and records its timing once its response body
+// is read or closed.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	recorder, _ := req.Context().Value(recorderKey{}).(*Recorder)
+	if recorder == nil {
+		return base.RoundTrip(req)
+	}
+
+	tr := &tracer{timing: Timing{
+		Method: req.Method,
+		Host:   req.URL.Host,
+		Start:  time.Now(),
+	}}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), tr.clientTrace()))
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		recorder.add(tr.done(0))
+		return nil, err
+	}
+	resp.Body = &body{ReadCloser: resp.Body, done: func() {
+		recorder.add(tr.done(resp.StatusCode))
+	}}
+	return resp, nil
+}
+
+// tracer collects the phase timings of a request. The hooks can be called
+// concurrently, e.g. when dialing multiple addresses.
+type tracer struct {
+	mu           sync.Mutex
+	timing       Timing
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wrote        time.Time
+	firstByte    time.Time
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.elapse(&t.timing.DNS, t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.set(&t.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			t.elapse(&t.timing.Connect, t.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			t.set(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.elapse(&t.timing.TLS, t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.timing.Reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.set(&t.wrote)
+		},
+		GotFirstResponseByte: func() {
+			t.set(&t.firstByte)
+		},
+	}
+}
+
+// set sets the time of an event to now.
+func (t *tracer) set(event *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*event = time.Now()
+}
+
+// elapse sets the duration of a phase which started at start.
+func (t *tracer) elapse(phase *time.Duration, start time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !start.IsZero() {
+		*phase = time.Since(start)
+	}
+}
+
+// done completes the timing of the request.
+func (t *tracer) done(status int) Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timing := t.timing
+	timing.Status = status
+	if !t.firstByte.IsZero() {
+		if !t.wrote.IsZero() {
+			timing.TTFB = t.firstByte.Sub(t.wrote)
+		}
+		timing.Transfer = time.Since(t.firstByte)
+	}
+	return timing
+}
+
+// body calls done once the response body is fully read or closed.
+type body struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
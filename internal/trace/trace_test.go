@@ -0,0 +1,66 @@
+package trace
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("world"))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport}}
+
+	ctx, recorder := WithRecorder(context.Background())
+	for range 2 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	timings := recorder.Timings()
+	if len(timings) != 2 {
+		t.Fatalf("recorded %d timings, want 2", len(timings))
+	}
+	first, second := timings[0], timings[1]
+	if first.Reused || first.Connect <= 0 || first.TLS <= 0 {
+		t.Errorf("first request on a new connection has timing %+v", first)
+	}
+	if !second.Reused || second.Connect != 0 || second.TLS != 0 {
+		t.Errorf("second request on a reused connection has timing %+v", second)
+	}
+	for _, timing := range timings {
+		if timing.Status != http.StatusOK || timing.Method != http.MethodGet {
+			t.Errorf("timing %+v, want status 200 of GET", timing)
+		}
+		if timing.TTFB <= 0 || timing.Transfer < 10*time.Millisecond {
+			t.Errorf("timing TTFB = %v, Transfer = %v, want TTFB > 0 and Transfer >= 10ms", timing.TTFB, timing.Transfer)
+		}
+	}
+}
+
+func TestTransportWithoutRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := &http.Client{Transport: &Transport{}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+}